@@ -0,0 +1,177 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"simple-uploader/internal/config"
+)
+
+func runConfig(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: minioctl.exe config get|set|unset|list|validate|path|import|export|fetch|keygen|sign")
+	}
+
+	fs := flag.NewFlagSet("config "+args[0], flag.ExitOnError)
+	profile := config.AddFlags(fs)
+
+	switch args[0] {
+	case "list", "show":
+		origin := fs.Bool("origin", false, "show where each value comes from")
+		_ = fs.Parse(args[1:])
+		return showConfig(*profile, *origin)
+
+	case "get":
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: minioctl.exe config get <key>")
+		}
+		return getSetting(*profile, fs.Arg(0))
+
+	case "set":
+		layer := fs.String("layer", "", "config file to change: machine or user (default: the user file if it exists)")
+		_ = fs.Parse(args[1:])
+		switch fs.NArg() {
+		case 1:
+			return setSetting(*profile, *layer, fs.Arg(0), nil)
+		case 2:
+			value := fs.Arg(1)
+			return setSetting(*profile, *layer, fs.Arg(0), &value)
+		}
+		return fmt.Errorf("usage: minioctl.exe config set [-layer machine|user] <key> <value>")
+
+	case "unset":
+		layer := fs.String("layer", "", "config file to change: machine or user (default: the user file if it exists)")
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: minioctl.exe config unset [-layer machine|user] <key>")
+		}
+		return unsetSetting(*profile, *layer, fs.Arg(0))
+
+	case "validate":
+		_ = fs.Parse(args[1:])
+		return validateConfig(*profile)
+
+	case "path":
+		layer := fs.String("layer", "", "print only the file of this layer: machine or user")
+		_ = fs.Parse(args[1:])
+		return printPaths(*layer)
+
+	case "import":
+		var opts importOptions
+		fs.StringVar(&opts.from, "from", "", "what to import: mc or rclone")
+		fs.StringVar(&opts.file, "file", "", "mc config.json or rclone.conf to read (default: the tool's own)")
+		fs.StringVar(&opts.name, "name", "", "mc alias or rclone remote to import")
+		fs.StringVar(&opts.bucket, "bucket", "", "bucket to use; required unless the section already has one")
+		fs.StringVar(&opts.layer, "layer", "", "config file to write: machine or user (default: the user file if it exists)")
+		fs.StringVar(&opts.store, "store", "os", "secret store for the secret key: os or file")
+		fs.BoolVar(&opts.plaintext, "plaintext", false, "write the secret key into config.json instead of a secret store")
+		_ = fs.Parse(args[1:])
+		opts.profile = *profile
+		return importConfig(opts)
+
+	case "fetch":
+		_ = fs.Parse(args[1:])
+		return fetchRemote(*profile)
+
+	case "keygen":
+		_ = fs.Parse(args[1:])
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: minioctl.exe config keygen <private-key-file>")
+		}
+		return generateSigningKey(fs.Arg(0))
+
+	case "sign":
+		key := fs.String("key", "", "private key file written by config keygen")
+		url := fs.String("url", "", "URL the config is published at, as in remote.url")
+		serial := fs.Int64("serial", time.Now().Unix(), "release number, higher than that of every earlier release")
+		valid := fs.Duration("valid", 90*24*time.Hour, "how long clients may fetch this release")
+		_ = fs.Parse(args[1:])
+		if *key == "" || *url == "" || fs.NArg() != 1 {
+			return fmt.Errorf("usage: minioctl.exe config sign -key <private-key-file> -url <url> [-serial N] [-valid 2160h] <config.json>")
+		}
+		return signConfig(*key, *url, *serial, *valid, fs.Arg(0))
+
+	case "export":
+		to := fs.String("to", "", "what to produce: mc or rclone")
+		file := fs.String("file", "", "mc config.json or rclone.conf to add the entry to, instead of printing it")
+		name := fs.String("name", "", "mc alias or rclone remote name (default: the profile name or minio-drive)")
+		_ = fs.Parse(args[1:])
+		return exportConfig(*profile, *to, *file, *name)
+	}
+	return fmt.Errorf("unknown config command %q", args[0])
+}
+
+// showConfig prints every effective value, and with origin the layer that
+// set it. The config is not validated, so a broken one can be inspected.
+func showConfig(profile string, origin bool) error {
+	cfg, err := loadSelected(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	settings, err := cfg.Settings()
+	if err != nil {
+		return err
+	}
+
+	if origin {
+		if err := printFiles(); err != nil {
+			return err
+		}
+		if r := cfg.RemoteStatus(); r != nil {
+			state := "not fetched"
+			if !r.FetchedAt.IsZero() {
+				state = "fetched " + r.FetchedAt.Local().Format(time.DateTime)
+			}
+			if r.Err != nil {
+				state += ", last fetch failed: " + r.Err.Error()
+			}
+			fmt.Printf("%-8s %s (%s)\n", config.LayerRemote, r.URL, state)
+		}
+		if name := cfg.ActiveProfile(); name != "" {
+			fmt.Printf("profile  %s\n", name)
+		}
+		fmt.Println()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	if origin {
+		fmt.Fprintln(w, "KEY\tVALUE\tORIGIN\t")
+	} else {
+		fmt.Fprintln(w, "KEY\tVALUE\t")
+	}
+	for _, s := range settings {
+		note := ""
+		if s.Locked != "" {
+			note = "read-only: " + s.Locked
+		}
+		if origin {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.Key, s.Value, s.Origin, note)
+		} else {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Value, note)
+		}
+	}
+	return w.Flush()
+}
+
+// printFiles lists the config files and the administrator policy, in
+// priority order, and whether each exists
+func printFiles() error {
+	files, err := config.Files()
+	if err != nil {
+		return err
+	}
+	files = append(files, config.Origin{Layer: config.LayerAdmin, Source: config.AdminPolicyPath()})
+	for _, f := range files {
+		state := "not found"
+		if _, err := os.Stat(f.Source); err == nil {
+			state = "loaded"
+		}
+		fmt.Printf("%-8s %s (%s)\n", f.Layer, f.Source, state)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/rclone"
+	"simple-uploader/internal/secret"
+)
+
+// defaultExportName names exported entries when no profile is active
+const defaultExportName = "minio-drive"
+
+type importOptions struct {
+	from    string
+	file    string
+	name    string
+	bucket  string
+	profile string
+	layer   string
+	store   string
+	// plaintext writes the secret key into config.json instead of store
+	plaintext bool
+}
+
+// importConfig writes an mc alias or rclone remote into the minio section
+// of a config file, or of a profile in it with -profile. The endpoint and
+// keys are replaced; the bucket and other settings are kept. The secret key
+// goes to a secret store unless plaintext is set.
+func importConfig(opts importOptions) error {
+	var (
+		m   *config.MinIOConfig
+		err error
+	)
+	switch opts.from {
+	case "mc":
+		if opts.name == "" {
+			return fmt.Errorf("-name is required: the mc alias to import")
+		}
+		file := opts.file
+		if file == "" {
+			if file, err = config.MCConfigPath(); err != nil {
+				return err
+			}
+		}
+		if m, err = config.ReadMCAlias(file, opts.name); err != nil {
+			return err
+		}
+	case "rclone":
+		file := opts.file
+		if file == "" {
+			if file, err = rclone.UserConfigPath(); err != nil {
+				return err
+			}
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if m, opts.name, err = rclone.ParseRemote(data, opts.name); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+	default:
+		return fmt.Errorf("-from must be mc or rclone")
+	}
+	if m.AccessKey == "" || m.SecretKey == "" {
+		return fmt.Errorf("%s has no access and secret key to import", opts.name)
+	}
+
+	path, err := editTarget(opts.layer)
+	if err != nil {
+		return err
+	}
+
+	section := "minio"
+	if opts.profile != "" {
+		section = "profiles." + opts.profile + ".minio"
+	}
+	keys := []string{"endpoint", "use_ssl", "access_key", "secret_key", "secret_ref", "credential_process"}
+	if opts.bucket != "" {
+		keys = append(keys, "bucket")
+	}
+	for _, key := range keys {
+		if err := config.CheckUnlocked(section + "." + key); err != nil {
+			return err
+		}
+	}
+
+	ref := ""
+	if !opts.plaintext {
+		name := opts.profile
+		if name == "" {
+			name = "default"
+		}
+		ref = secret.Ref{Store: opts.store, Name: name}.String()
+		if _, err := secret.ParseRef(ref); err != nil {
+			return err
+		}
+		secret.Passphrase = secret.PromptPassphrase()
+	}
+
+	err = config.EditFile(path, func(doc map[string]any) error {
+		s := doc
+		if opts.profile != "" {
+			profiles, _ := doc["profiles"].(map[string]any)
+			if profiles == nil {
+				profiles = map[string]any{}
+				doc["profiles"] = profiles
+			}
+			p, _ := profiles[opts.profile].(map[string]any)
+			if p == nil {
+				p = map[string]any{}
+				profiles[opts.profile] = p
+			}
+			s = p
+		}
+		minio, _ := s["minio"].(map[string]any)
+		if minio == nil {
+			minio = map[string]any{}
+			s["minio"] = minio
+		}
+
+		if opts.bucket != "" {
+			minio["bucket"] = opts.bucket
+		}
+		if b, _ := minio["bucket"].(string); b == "" {
+			return fmt.Errorf("-bucket is required: %s has no bucket yet", section)
+		}
+
+		minio["endpoint"] = m.Endpoint
+		minio["use_ssl"] = m.UseSSL
+		minio["access_key"] = m.AccessKey
+		delete(minio, "credential_process")
+		if ref != "" {
+			minio["secret_ref"] = ref
+			delete(minio, "secret_key")
+		} else {
+			minio["secret_key"] = m.SecretKey
+			delete(minio, "secret_ref")
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	// The secret is stored once the config that refers to it is written,
+	// so a rejected edit leaves the store untouched
+	if ref != "" {
+		if err := secret.Put(ref, m.SecretKey); err != nil {
+			return fmt.Errorf("imported into %s, but storing the secret key failed; run minioctl.exe secrets set %s: %w", path, ref, err)
+		}
+	}
+
+	fmt.Printf("Imported %s %q into %s of %s\n", opts.from, opts.name, section, path)
+	if ref != "" {
+		fmt.Printf("The secret key is in %s\n", ref)
+	} else {
+		fmt.Printf("Warning: the secret key is stored in plaintext in %s\n", path)
+	}
+	return nil
+}
+
+// exportConfig prints the effective minio section as an mc alias or an
+// rclone remote, or adds it to a file. Keys from a secret store or a
+// credential process are resolved, so the output holds the secret key.
+func exportConfig(profile, to, file, name string) error {
+	cfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if name == "" {
+		name = cfg.ActiveProfile()
+	}
+	if name == "" {
+		name = defaultExportName
+	}
+	m := cfg.MinIO
+
+	switch to {
+	case "mc":
+		expires, err := resolveProcess(&m)
+		if err != nil {
+			return err
+		}
+		warnExpiry(expires)
+		alias := m.MCAlias()
+		if file == "" {
+			out, err := json.MarshalIndent(map[string]config.MCAlias{name: alias}, "", "\t")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+		} else if err := config.WriteMCAlias(file, name, alias); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Use it as %s/%s\n", name, m.Bucket)
+
+	case "rclone":
+		section, expires, err := rclone.RenderRemote(name, &m)
+		if err != nil {
+			return err
+		}
+		warnExpiry(expires)
+		if file == "" {
+			fmt.Print(section)
+		} else {
+			data, err := os.ReadFile(file)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+				return err
+			}
+			if err := os.WriteFile(file, rclone.ReplaceRemote(data, name, section), 0600); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintf(os.Stderr, "Use it as %s:%s\n", name, m.Bucket)
+
+	default:
+		return fmt.Errorf("-to must be mc or rclone")
+	}
+
+	if file != "" {
+		fmt.Fprintf(os.Stderr, "Wrote %s to %s\n", name, file)
+	}
+	return nil
+}
+
+// resolveProcess replaces a credential process with the keys it prints,
+// returning when they expire
+func resolveProcess(m *config.MinIOConfig) (time.Time, error) {
+	if m.CredentialProcess == "" {
+		return time.Time{}, nil
+	}
+	creds, err := secret.ProcessFor(m.CredentialProcess).Get(context.Background())
+	if err != nil {
+		return time.Time{}, err
+	}
+	if creds.SessionToken != "" {
+		return time.Time{}, fmt.Errorf("the credential process returns session keys, which mc aliases cannot hold")
+	}
+	m.AccessKey, m.SecretKey, m.CredentialProcess = creds.AccessKeyID, creds.SecretAccessKey, ""
+	return creds.Expiration, nil
+}
+
+// warnExpiry notes when exported keys come from a credential process and
+// stop working
+func warnExpiry(expires time.Time) {
+	if !expires.IsZero() {
+		fmt.Fprintf(os.Stderr, "Warning: these keys come from credential_process and expire at %s\n", expires.Format(time.RFC3339))
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		printUsage()
+		os.Exit(1)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "usage":
+		err = runUsage(os.Args[2:])
+	case "verify":
+		err = runVerify(os.Args[2:])
+	case "sync":
+		err = runSync(os.Args[2:])
+	case "queue":
+		err = runQueue(os.Args[2:])
+	case "tag":
+		err = runTag(os.Args[2:])
+	case "probe":
+		err = runProbe(os.Args[2:])
+	case "secrets":
+		err = runSecrets(os.Args[2:])
+	case "config":
+		err = runConfig(os.Args[2:])
+	default:
+		printUsage()
+		os.Exit(1)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func printUsage() {
+	fmt.Println("MinIO Drive Command Line Tool")
+	fmt.Println()
+	fmt.Println("Usage:")
+	fmt.Println("  minioctl.exe usage [-format table|json|csv] [prefix]")
+	fmt.Println("      Report bucket usage per folder, extension and age")
+	fmt.Println("  minioctl.exe verify [-json] <folder> [prefix]")
+	fmt.Println("      Compare a local folder with a prefix")
+	fmt.Println("  minioctl.exe sync [-delete] [-dry-run] [-max-delete N] [-max-delete-percent P] <folder> <prefix>")
+	fmt.Println("      Mirror a local folder to a prefix (one way)")
+	fmt.Println("  minioctl.exe sync -two-way [-state file] [-dry-run] [-max-delete N] <folder> <prefix>")
+	fmt.Println("      Sync a local folder and a prefix in both directions")
+	fmt.Println("  minioctl.exe queue list")
+	fmt.Println("      List uploads waiting in the offline queue")
+	fmt.Println("  minioctl.exe queue cancel [-all] [id ...]")
+	fmt.Println("      Cancel queued uploads")
+	fmt.Println("  minioctl.exe tag get [-json] <key>")
+	fmt.Println("      Show the tags of an object")
+	fmt.Println("  minioctl.exe tag set <key> key=value ...")
+	fmt.Println("      Replace the tags of an object")
+	fmt.Println("  minioctl.exe tag rm <key>")
+	fmt.Println("      Remove all tags from an object")
+	fmt.Println("  minioctl.exe probe [-json]")
+	fmt.Println("      Check which operations the credentials may perform on the bucket")
+	fmt.Println("  minioctl.exe secrets migrate [-store os|file] [-layer machine|user] [-dry-run]")
+	fmt.Println("      Move plaintext secret keys from a config file into a secret store")
+	fmt.Println("  minioctl.exe secrets set <store:name>")
+	fmt.Println("      Store a secret key and print the secret_ref to use")
+	fmt.Println("  minioctl.exe config list [-origin]")
+	fmt.Println("      Print the effective config, and with -origin the layer each value comes from")
+	fmt.Println("  minioctl.exe config get <key>")
+	fmt.Println("      Print one value, such as mount.drive_letter, or a whole section")
+	fmt.Println("  minioctl.exe config set [-layer machine|user] <key> <value>")
+	fmt.Println("      Change a value in a config file, refusing values that fail validation")
+	fmt.Println("  minioctl.exe config unset [-layer machine|user] <key>")
+	fmt.Println("      Remove a value from a config file")
+	fmt.Println("  minioctl.exe config validate")
+	fmt.Println("      Check the config with every profile")
+	fmt.Println("  minioctl.exe config path [-layer machine|user]")
+	fmt.Println("      Show the config files and which one set and unset change")
+	fmt.Println("  minioctl.exe config import -from mc|rclone [-file F] [-name N] [-bucket B] [-layer machine|user] [-store os|file | -plaintext]")
+	fmt.Println("      Copy the endpoint and keys of an mc alias or rclone remote into the config")
+	fmt.Println("  minioctl.exe config export -to mc|rclone [-name N] [-file F]")
+	fmt.Println("      Print the config as an mc alias or rclone remote, or add it to a file")
+	fmt.Println("  minioctl.exe config fetch")
+	fmt.Println("      Fetch the remote config now")
+	fmt.Println("  minioctl.exe config keygen <private-key-file>")
+	fmt.Println("      Create a key for signing the remote config and print its public key")
+	fmt.Println("  minioctl.exe config sign -key <private-key-file> -url <url> [-serial N] [-valid D] <config.json>")
+	fmt.Println("      Sign a remote config for its URL, writing <config.json>.sig")
+	fmt.Println()
+	fmt.Println("Every command accepts -profile <name> to select a config profile and")
+	fmt.Println("-set key=value to override a config value.")
+}
+
+// loadClient loads the config for the given profile and connects to MinIO
+func loadClient(profile string) (*config.Config, *minio.Client, error) {
+	cfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	client, err := minio.NewClient(&cfg.MinIO)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, client, nil
+}
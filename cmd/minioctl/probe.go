@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+)
+
+func runProbe(args []string) error {
+	fs := flag.NewFlagSet("probe", flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	_, client, err := loadClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	report := client.Probe(ctx)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("Endpoint: %s\n", report.Endpoint)
+		fmt.Printf("Bucket:   %s\n\n", report.Bucket)
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+		fmt.Fprintf(w, "reachable\t%s\t%s\n", yesNo(report.Reachable), report.Error)
+		if report.Reachable {
+			fmt.Fprintf(w, "bucket exists\t%s\t\n", yesNo(report.BucketExists))
+		}
+		if report.BucketExists {
+			for _, c := range []struct {
+				name string
+				cap  minio.Capability
+			}{
+				{"list", report.List},
+				{"put", report.Put},
+				{"get", report.Get},
+				{"delete", report.Delete},
+			} {
+				result := yesNo(c.cap.Allowed)
+				if !c.cap.Tried {
+					result = "-"
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, result, c.cap.Error)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if report.Put.Allowed && !report.Delete.Allowed {
+			fmt.Printf("\nThe scratch object %s could not be deleted.\n", report.ScratchKey)
+		}
+	}
+
+	if err := report.UploadError(); err != nil {
+		if !*asJSON {
+			fmt.Printf("\n%v\n", err)
+		}
+		os.Exit(2)
+	}
+	return nil
+}
+
+func yesNo(ok bool) string {
+	if ok {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/queue"
+
+	"github.com/dustin/go-humanize"
+)
+
+func runQueue(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: minioctl.exe queue list | cancel [-all] [id ...]")
+	}
+
+	fs := flag.NewFlagSet("queue "+args[0], flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	all := fs.Bool("all", false, "cancel every queued upload")
+	_ = fs.Parse(args[1:])
+
+	cfg, err := config.LoadProfile(*profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	q, err := queue.OpenFromConfig(&cfg.Queue)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "list":
+		items, err := q.List()
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			fmt.Println("No queued uploads")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tFILE\tKEY\tSIZE\tATTEMPTS\tLAST ERROR")
+		for _, item := range items {
+			lastError := item.LastError
+			if item.GivenUp {
+				lastError = "given up: " + lastError
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", item.ID, item.Path, item.Key,
+				humanize.IBytes(uint64(item.Size)), item.Attempts, lastError)
+		}
+		return w.Flush()
+
+	case "cancel":
+		ids := fs.Args()
+		if *all {
+			items, err := q.List()
+			if err != nil {
+				return err
+			}
+			ids = ids[:0]
+			for _, item := range items {
+				ids = append(ids, item.ID)
+			}
+		}
+		if len(ids) == 0 {
+			return fmt.Errorf("specify queue item IDs or -all")
+		}
+
+		for _, id := range ids {
+			if err := q.Remove(id); err != nil {
+				return err
+			}
+			fmt.Printf("Cancelled %s\n", id)
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("unknown queue command %q", args[0])
+	}
+}
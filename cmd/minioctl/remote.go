@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/config"
+)
+
+// fetchRemote downloads the remote config now, whether or not it is due
+func fetchRemote(profile string) error {
+	cfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg.Remote.URL == "" {
+		return fmt.Errorf("remote.url is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	changed, err := config.FetchRemote(ctx, cfg.Remote)
+	if err != nil {
+		return err
+	}
+	if changed {
+		fmt.Printf("Fetched a new version of %s\n", cfg.Remote.URL)
+	} else {
+		fmt.Printf("%s is unchanged\n", cfg.Remote.URL)
+	}
+	return nil
+}
+
+// generateSigningKey writes a new Ed25519 private key for signing the
+// remote config and prints the public key to pin in remote.public_key
+func generateSigningKey(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(private.Seed()) + "\n"
+	if err := os.WriteFile(path, []byte(encoded), 0600); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the private key to %s; keep it off the clients\n", path)
+	fmt.Printf("\"public_key\": %q\n", base64.StdEncoding.EncodeToString(public))
+	return nil
+}
+
+// signConfig writes <file>.sig, the signature to publish next to a remote
+// config at rawURL. The config must be published byte for byte as signed.
+func signConfig(keyPath, rawURL string, serial int64, valid time.Duration, file string) error {
+	encoded, err := os.ReadFile(keyPath)
+	if err != nil {
+		return err
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("%s is not a key written by config keygen", keyPath)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+
+	expires := time.Now().Add(valid)
+	sig, err := config.SignRemote(ed25519.NewKeyFromSeed(seed), rawURL, serial, expires, data)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(file+".sig", sig, 0644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s.sig with serial %d, valid until %s; publish it next to %s\n",
+		file, serial, expires.Format(time.DateTime), file)
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/secret"
+)
+
+func runSecrets(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: minioctl.exe secrets migrate [-store os|file] [-layer machine|user] [-dry-run] | set <store:name>")
+	}
+	secret.Passphrase = secret.PromptPassphrase()
+
+	fs := flag.NewFlagSet("secrets "+args[0], flag.ExitOnError)
+	store := fs.String("store", secret.StoreOS, "where to keep the secrets: os or file")
+	layer := fs.String("layer", "", "config file to migrate: machine or user (default: the user file if it exists)")
+	dryRun := fs.Bool("dry-run", false, "only show what would be moved")
+	_ = fs.Parse(args[1:])
+
+	switch args[0] {
+	case "migrate":
+		return migrateSecrets(*store, *layer, *dryRun)
+
+	case "set":
+		if fs.NArg() != 1 {
+			return fmt.Errorf("usage: minioctl.exe secrets set <store:name>")
+		}
+		ref := fs.Arg(0)
+		if _, err := secret.ParseRef(ref); err != nil {
+			return err
+		}
+		value, err := secret.ReadLine("Secret key: ", true)
+		if err != nil {
+			return err
+		}
+		if value == "" {
+			return fmt.Errorf("empty secret")
+		}
+		if err := secret.Put(ref, value); err != nil {
+			return err
+		}
+		fmt.Printf("Stored %s; set \"secret_ref\": %q in config.json to use it\n", ref, ref)
+		return nil
+	}
+
+	return fmt.Errorf("unknown secrets command %q", args[0])
+}
+
+// migrateSecrets moves every plaintext secret_key in one config file into
+// a store and leaves a secret_ref in its place. The top-level section is
+// stored as "<layer>-default", profiles as "<layer>-<profile>", so the
+// machine and user files never share an entry. Without a layer, the user
+// file is migrated when it exists, otherwise the machine file.
+func migrateSecrets(store, layer string, dryRun bool) error {
+	file, err := layerOrigin(layer)
+	if err != nil {
+		return err
+	}
+	path := file.Source
+
+	// The OS store belongs to the current user, while the machine file is
+	// shared by everyone on the PC
+	if file.Layer == config.LayerMachine && store == secret.StoreOS {
+		return fmt.Errorf("%s is shared by every user of this PC, but the %s store only holds secrets for the current user; use -store %s",
+			path, secret.StoreOS, secret.StoreFile)
+	}
+
+	moved := 0
+	migrate := func(keyPath, name string, section any) error {
+		m, ok := section.(map[string]any)
+		if !ok {
+			return nil
+		}
+		key, _ := m["secret_key"].(string)
+		if ref, _ := m["secret_ref"].(string); key == "" || ref != "" {
+			return nil
+		}
+		ref := secret.Ref{Store: store, Name: file.Layer + "-" + name}.String()
+		if _, err := secret.ParseRef(ref); err != nil {
+			return fmt.Errorf("%s: %w", keyPath, err)
+		}
+
+		fmt.Printf("%s.secret_key -> %s\n", keyPath, ref)
+		moved++
+		if dryRun {
+			return nil
+		}
+		if err := secret.Put(ref, key); err != nil {
+			return err
+		}
+		m["secret_ref"] = ref
+		delete(m, "secret_key")
+		return nil
+	}
+
+	err = config.EditFile(path, func(doc map[string]any) error {
+		if err := migrate("minio", "default", doc["minio"]); err != nil {
+			return err
+		}
+		profiles, _ := doc["profiles"].(map[string]any)
+		names := make([]string, 0, len(profiles))
+		for name := range profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p, _ := profiles[name].(map[string]any)
+			if err := migrate("profiles."+name+".minio", name, p["minio"]); err != nil {
+				return err
+			}
+		}
+		if moved == 0 || dryRun {
+			return errNothingToSave
+		}
+		return nil
+	})
+	if errors.Is(err, errNothingToSave) {
+		if moved == 0 {
+			fmt.Printf("No plaintext secrets in %s\n", path)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Printf("Moved %d secret(s) from %s to the %s store\n", moved, path, store)
+	return nil
+}
+
+// errNothingToSave ends a config edit without writing the file
+var errNothingToSave = errors.New("nothing to save")
+
+// layerFile returns the config file of a layer, or when layer is empty
+// the user file if it exists and otherwise the machine file
+func layerFile(layer string) (string, error) {
+	f, err := layerOrigin(layer)
+	return f.Source, err
+}
+
+// layerOrigin is layerFile, also telling which layer the file is
+func layerOrigin(layer string) (config.Origin, error) {
+	files, err := config.Files()
+	if err != nil {
+		return config.Origin{}, err
+	}
+
+	if layer != "" {
+		for _, f := range files {
+			if f.Layer == layer {
+				return f, nil
+			}
+		}
+		return config.Origin{}, fmt.Errorf("unknown config layer %q, use %s or %s", layer, config.LayerMachine, config.LayerUser)
+	}
+
+	for i := len(files) - 1; i >= 0; i-- {
+		if _, err := os.Stat(files[i].Source); err == nil {
+			return files[i], nil
+		}
+	}
+	return config.Origin{}, fmt.Errorf("no config file found")
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/secret"
+)
+
+// loadSelected reads the config and selects the profile without validating
+// it, so that a broken config can still be inspected and repaired
+func loadSelected(profile string) (*config.Config, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if profile == "" {
+		profile = os.Getenv(config.ProfileEnv)
+	}
+	if err := cfg.UseProfile(profile); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// editTarget returns the config file set, unset and import change. The
+// user file is created when it is asked for and does not exist yet.
+func editTarget(layer string) (string, error) {
+	if layer != config.LayerUser {
+		return layerFile(layer)
+	}
+	path, err := config.UserConfigPath()
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return "", err
+		}
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("{\n  \"version\": %d\n}\n", config.CurrentVersion)), 0600); err != nil {
+			return "", err
+		}
+	}
+	return path, nil
+}
+
+// getSetting prints the effective value of a key, or of every key below it
+// such as all of "mount". Strings are printed without quotes and secrets
+// are masked.
+func getSetting(profile, key string) error {
+	if _, err := config.KeyType(key); err != nil {
+		return err
+	}
+	cfg, err := loadSelected(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	settings, err := cfg.Settings()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	found := false
+	for _, s := range settings {
+		switch {
+		case s.Key == key:
+			var str string
+			if json.Unmarshal([]byte(s.Value), &str) == nil {
+				fmt.Println(str)
+			} else {
+				fmt.Println(s.Value)
+			}
+			return nil
+		case strings.HasPrefix(s.Key, key+"."):
+			fmt.Fprintf(w, "%s\t%s\n", s.Key, s.Value)
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("%s is not set", key)
+	}
+	return w.Flush()
+}
+
+// setSetting stores a value in one config file. With a profile in effect,
+// minio and mount keys are stored in that profile. A secret key given
+// without a value is read from the terminal without echo.
+func setSetting(profile, layer, key string, value *string) error {
+	path, err := editTarget(layer)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadSelected(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	keyPath := cfg.KeyPath(key)
+	if _, err := config.KeyType(keyPath); err != nil {
+		return err
+	}
+	if err := config.CheckUnlocked(keyPath); err != nil {
+		return err
+	}
+
+	if value == nil {
+		if !config.IsSecret(keyPath) {
+			return fmt.Errorf("usage: minioctl.exe config set [-layer machine|user] <key> <value>")
+		}
+		v, err := secret.ReadLine("Secret key: ", true)
+		if err != nil {
+			return err
+		}
+		value = &v
+	}
+
+	err = config.EditFile(path, func(doc map[string]any) error {
+		return config.SetKey(doc, keyPath, *value)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	shown := *value
+	if config.IsSecret(keyPath) {
+		shown = "********"
+	}
+	fmt.Printf("Set %s = %s in %s\n", keyPath, shown, path)
+	if config.IsSecret(keyPath) {
+		fmt.Println("The key is stored in plain text; \"minioctl.exe secrets migrate\" moves it to a secret store")
+	}
+	warnOverridden(profile, key, path)
+	return nil
+}
+
+// unsetSetting removes a value from one config file, so the value from a
+// lower layer or the default applies again
+func unsetSetting(profile, layer, key string) error {
+	path, err := layerFile(layer)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadSelected(profile)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	keyPath := cfg.KeyPath(key)
+	if _, err := config.KeyType(keyPath); err != nil {
+		return err
+	}
+	if err := config.CheckUnlocked(keyPath); err != nil {
+		return err
+	}
+
+	err = config.EditFile(path, func(doc map[string]any) error {
+		if !config.UnsetKey(doc, keyPath) {
+			return fmt.Errorf("%s is not set in this file", keyPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fmt.Printf("Removed %s from %s\n", keyPath, path)
+	return nil
+}
+
+// warnOverridden notes when the file just changed does not decide the
+// effective value of key, because a higher layer sets it
+func warnOverridden(profile, key, path string) {
+	cfg, err := loadSelected(profile)
+	if err != nil {
+		return
+	}
+	if o := cfg.Origin(key); o.Source != path {
+		fmt.Printf("Note: %s is overridden by %s\n", key, o)
+	}
+}
+
+// validateConfig checks the config with every profile, and that the
+// selected profile's secret can be read
+func validateConfig(profile string) error {
+	if err := config.Check(); err != nil {
+		return err
+	}
+	secret.Passphrase = secret.PromptPassphrase()
+	if _, err := config.LoadProfile(profile); err != nil {
+		return err
+	}
+	fmt.Println("Config is valid")
+	return nil
+}
+
+// printPaths lists the config files, or prints the one of a layer
+func printPaths(layer string) error {
+	if layer != "" {
+		files, err := config.Files()
+		if err != nil {
+			return err
+		}
+		for _, f := range files {
+			if f.Layer == layer {
+				fmt.Println(f.Source)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown config layer %q, use %s or %s", layer, config.LayerMachine, config.LayerUser)
+	}
+
+	if err := printFiles(); err != nil {
+		return err
+	}
+	if cache, err := config.RemoteCachePath(); err == nil {
+		fmt.Printf("%-8s %s (cached copy of remote.url)\n", config.LayerRemote, cache)
+	}
+	if path, err := layerFile(""); err == nil {
+		fmt.Printf("\nset and unset change %s\n", path)
+	}
+	return nil
+}
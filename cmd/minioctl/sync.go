@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+	"simple-uploader/internal/syncer"
+
+	"github.com/dustin/go-humanize"
+)
+
+// syncEngine is implemented by the one-way and two-way sync engines
+type syncEngine interface {
+	Plan(ctx context.Context) (*syncer.Plan, error)
+	Apply(ctx context.Context, plan *syncer.Plan, report func(syncer.Action, error)) error
+}
+
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	twoWay := fs.Bool("two-way", false, "sync changes in both directions, keeping conflicts as copies")
+	statePath := fs.String("state", "", "two-way sync state file (default: under the user config dir)")
+	del := fs.Bool("delete", false, "delete remote objects that were removed locally (one-way only)")
+	dryRun := fs.Bool("dry-run", false, "print the plan without changing anything")
+	maxDelete := fs.Int("max-delete", 100, "abort when more deletions are planned (0 for no limit)")
+	maxDeletePercent := fs.Float64("max-delete-percent", 50, "abort when more than this percentage of remote objects would be deleted (0 for no limit)")
+	asJSON := fs.Bool("json", false, "print the plan as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 2 {
+		return fmt.Errorf("usage: minioctl.exe sync [-two-way] [-delete] [-dry-run] <folder> <prefix>")
+	}
+
+	cfg, client, err := loadClient(*profile)
+	if err != nil {
+		return err
+	}
+	if err := client.SetUploadOptions(minio.OptionsFromConfig(cfg)); err != nil {
+		return err
+	}
+
+	localDir, prefix := fs.Arg(0), fs.Arg(1)
+
+	var engine syncEngine
+	if *twoWay {
+		path := *statePath
+		if path == "" {
+			if path, err = syncer.DefaultStatePath(localDir, client.Bucket(), prefix); err != nil {
+				return err
+			}
+		}
+		engine = &syncer.BiSync{
+			Client:    client,
+			LocalDir:  localDir,
+			Prefix:    prefix,
+			StatePath: path,
+			Filters:   cfg.Filters,
+			Options: syncer.BiSyncOptions{
+				MaxDelete:        *maxDelete,
+				MaxDeletePercent: *maxDeletePercent,
+			},
+		}
+	} else {
+		engine = &syncer.Mirror{
+			Client:   client,
+			LocalDir: localDir,
+			Prefix:   prefix,
+			Filters:  cfg.Filters,
+			Options: syncer.MirrorOptions{
+				Delete:           *del,
+				MaxDelete:        *maxDelete,
+				MaxDeletePercent: *maxDeletePercent,
+			},
+		}
+	}
+
+	ctx := context.Background()
+	plan, planErr := engine.Plan(ctx)
+	if plan == nil {
+		return planErr
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(plan); err != nil {
+			return err
+		}
+	} else {
+		printPlan(plan)
+	}
+
+	// The plan is still shown when the delete threshold trips
+	if planErr != nil {
+		return planErr
+	}
+	if *dryRun || len(plan.Actions) == 0 {
+		return nil
+	}
+
+	return engine.Apply(ctx, plan, func(a syncer.Action, err error) {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "FAILED %s %s: %v\n", a.Op, a.Path, err)
+		} else if !*asJSON {
+			fmt.Printf("done   %s %s\n", a.Op, a.Path)
+		}
+	})
+}
+
+func printPlan(plan *syncer.Plan) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, a := range plan.Actions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", a.Op, a.Path, humanize.IBytes(uint64(a.Size)), a.Reason)
+	}
+	_ = w.Flush()
+
+	fmt.Printf("\n%d to upload, %d to download, %d to delete remotely, %d to delete locally, %d conflicts, %d unchanged\n",
+		plan.Count(syncer.OpUpload), plan.Count(syncer.OpDownload),
+		plan.Count(syncer.OpDeleteRemote), plan.Count(syncer.OpDeleteLocal),
+		plan.Count(syncer.OpConflict), plan.Unchanged)
+}
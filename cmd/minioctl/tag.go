@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"simple-uploader/internal/config"
+)
+
+func runTag(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: minioctl.exe tag get | set | rm <key> [key=value ...]")
+	}
+
+	fs := flag.NewFlagSet("tag "+args[0], flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	asJSON := fs.Bool("json", false, "print tags as JSON")
+	_ = fs.Parse(args[1:])
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("specify an object key")
+	}
+	key := fs.Arg(0)
+
+	_, client, err := loadClient(*profile)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "get":
+		tags, err := client.GetTags(ctx, key)
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(tags)
+		}
+		names := make([]string, 0, len(tags))
+		for k := range tags {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			fmt.Printf("%s=%s\n", k, tags[k])
+		}
+		return nil
+
+	case "set":
+		tags := make(map[string]string)
+		for _, arg := range fs.Args()[1:] {
+			k, v, ok := strings.Cut(arg, "=")
+			if !ok || k == "" {
+				return fmt.Errorf("expected key=value, got %q", arg)
+			}
+			tags[k] = v
+		}
+		if len(tags) == 0 {
+			return fmt.Errorf("specify at least one key=value tag (use tag rm to remove all)")
+		}
+		if err := client.SetTags(ctx, key, tags); err != nil {
+			return err
+		}
+		fmt.Printf("Replaced tags of %s\n", key)
+		return nil
+
+	case "rm":
+		if err := client.RemoveTags(ctx, key); err != nil {
+			return err
+		}
+		fmt.Printf("Removed tags from %s\n", key)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown tag command %q", args[0])
+	}
+}
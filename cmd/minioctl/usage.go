@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+
+	"github.com/dustin/go-humanize"
+)
+
+func runUsage(args []string) error {
+	fs := flag.NewFlagSet("usage", flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	_ = fs.Parse(args)
+
+	prefix := fs.Arg(0)
+
+	_, client, err := loadClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	report, err := client.Usage(context.Background(), prefix)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "table":
+		return writeUsageTable(os.Stdout, report)
+	case "json":
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	case "csv":
+		return writeUsageCSV(os.Stdout, report)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func writeUsageTable(out io.Writer, report *minio.UsageReport) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Bucket:\t%s\n", report.Bucket)
+	if report.Prefix != "" {
+		fmt.Fprintf(w, "Prefix:\t%s\n", report.Prefix)
+	}
+	fmt.Fprintf(w, "Total:\t%s\t%d objects\n", humanize.IBytes(uint64(report.Bytes)), report.Objects)
+
+	sections := []struct {
+		title string
+		stats []minio.UsageStat
+	}{
+		{"FOLDER", report.Folders},
+		{"EXTENSION", report.Extensions},
+		{"AGE", report.Ages},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(w, "\n%s\tSIZE\tOBJECTS\n", section.title)
+		for _, stat := range section.stats {
+			fmt.Fprintf(w, "%s\t%s\t%d\n", stat.Name, humanize.IBytes(uint64(stat.Bytes)), stat.Objects)
+		}
+	}
+
+	return w.Flush()
+}
+
+func writeUsageCSV(out io.Writer, report *minio.UsageReport) error {
+	w := csv.NewWriter(out)
+	_ = w.Write([]string{"group", "name", "bytes", "objects"})
+	_ = w.Write([]string{"total", report.Prefix, strconv.FormatInt(report.Bytes, 10), strconv.FormatInt(report.Objects, 10)})
+
+	groups := []struct {
+		name  string
+		stats []minio.UsageStat
+	}{
+		{"folder", report.Folders},
+		{"extension", report.Extensions},
+		{"age", report.Ages},
+	}
+	for _, group := range groups {
+		for _, stat := range group.stats {
+			_ = w.Write([]string{group.name, stat.Name, strconv.FormatInt(stat.Bytes, 10), strconv.FormatInt(stat.Objects, 10)})
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"simple-uploader/internal/config"
+)
+
+func runVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	profile := config.AddFlags(fs)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	_ = fs.Parse(args)
+
+	if fs.NArg() < 1 {
+		return fmt.Errorf("usage: minioctl.exe verify [-profile name] [-json] <folder> [prefix]")
+	}
+	localDir := fs.Arg(0)
+	prefix := fs.Arg(1)
+
+	if info, err := os.Stat(localDir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a folder", localDir)
+	}
+
+	cfg, client, err := loadClient(*profile)
+	if err != nil {
+		return err
+	}
+
+	report, err := client.VerifyTree(context.Background(), localDir, prefix, cfg.Filters)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, p := range report.Missing {
+			fmt.Printf("MISSING   %s\n", p)
+		}
+		for _, p := range report.Extra {
+			fmt.Printf("EXTRA     %s\n", p)
+		}
+		for _, m := range report.Mismatched {
+			fmt.Printf("MISMATCH  %s (%s)\n", m.Path, m.Reason)
+		}
+		fmt.Printf("\n%d matched, %d missing, %d extra, %d mismatched\n",
+			report.Matched, len(report.Missing), len(report.Extra), len(report.Mismatched))
+	}
+
+	if !report.OK() {
+		os.Exit(2)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"simple-uploader/internal/config"
+
+	"github.com/getlantern/systray"
+)
+
+// lockedMenu lists the settings pinned by the administrator policy in a
+// read-only submenu, each with the reason as its tooltip. systray cannot
+// remove items, so entries are reused and hidden when the list shrinks.
+type lockedMenu struct {
+	menu  *systray.MenuItem
+	items []*systray.MenuItem
+}
+
+// locked is the administrator submenu; it is only used on the menu goroutine
+var locked *lockedMenu
+
+// addLockedMenu adds the administrator submenu for the running config
+func addLockedMenu() {
+	locked = &lockedMenu{
+		menu: systray.AddMenuItem("Managed by administrator", "Settings you cannot change"),
+	}
+	cfg, _ := current()
+	locked.update(cfg)
+}
+
+// update shows the locks of cfg, hiding the submenu when there are none
+func (m *lockedMenu) update(cfg *config.Config) {
+	locks := cfg.Locks()
+	for i, l := range locks {
+		if i == len(m.items) {
+			item := m.menu.AddSubMenuItem("", "")
+			item.Disable()
+			m.items = append(m.items, item)
+		}
+		m.items[i].SetTitle(fmt.Sprintf("%s = %s", l.Key, l.Value))
+		m.items[i].SetTooltip(l.Reason)
+		m.items[i].Show()
+	}
+	for _, item := range m.items[len(locks):] {
+		item.Hide()
+	}
+
+	if len(locks) == 0 {
+		m.menu.Hide()
+	} else {
+		m.menu.Show()
+	}
+}
@@ -1,56 +1,68 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"simple-uploader/internal/config"
 	"simple-uploader/internal/icon"
+	"simple-uploader/internal/minio"
 	"simple-uploader/internal/rclone"
 
+	"github.com/dustin/go-humanize"
 	"github.com/gen2brain/beeep"
 	"github.com/getlantern/systray"
 )
 
-var (
+// session is a config together with the rclone manager built from it
+type session struct {
 	cfg     *config.Config
 	manager *rclone.Manager
-)
+}
+
+// running is the session in effect. The menu loop replaces it on a profile
+// switch or reload while other goroutines read it, so each function takes
+// one snapshot with current and works with that.
+var running atomic.Pointer[session]
+
+// current returns the running config and rclone manager
+func current() (*config.Config, *rclone.Manager) {
+	s := running.Load()
+	return s.cfg, s.manager
+}
 
 func main() {
-	var err error
+	profile := config.AddFlags(flag.CommandLine)
+	flag.Parse()
 
 	// Load configuration
-	cfg, err = config.Load()
+	cfg, err := config.LoadProfile(*profile)
 	if err != nil {
 		showError(fmt.Sprintf("Failed to load config: %v", err))
 		os.Exit(1)
 	}
 
 	// Create rclone manager
-	manager, err = rclone.NewManager(cfg)
+	manager, err := rclone.NewManager(cfg)
 	if err != nil {
 		showError(fmt.Sprintf("Failed to initialize: %v", err))
 		os.Exit(1)
 	}
+	running.Store(&session{cfg: cfg, manager: manager})
 
 	// Run system tray
 	systray.Run(onReady, onExit)
 }
 
 func onReady() {
+	cfg, _ := current()
 	systray.SetIcon(icon.Data)
 
-	// Set title based on mount type
-	if cfg.IsWinFsp() {
-		systray.SetTitle("MinIO Mount")
-		systray.SetTooltip("MinIO Cloud Drive (WinFsp)")
-	} else {
-		systray.SetTitle("MinIO WebDAV")
-		systray.SetTooltip("MinIO Cloud WebDAV Server")
-	}
-
 	// Menu items
 	mStart := systray.AddMenuItem("Start", "Start server/mount")
 	mStop := systray.AddMenuItem("Stop", "Stop server/mount")
@@ -68,17 +80,30 @@ func onReady() {
 	systray.AddSeparator()
 
 	// Show mount type
-	mountType := "WebDAV"
-	if cfg.IsWinFsp() {
-		mountType = "WinFsp"
-	}
-	mType := systray.AddMenuItem(fmt.Sprintf("Mode: %s", mountType), "Mount type")
+	mType := systray.AddMenuItem("", "Mount type")
 	mType.Disable()
+	updateTitles(mType)
+	addLockedMenu()
+
+	addProfileMenu()
+
+	systray.AddSeparator()
+
+	mUsage := systray.AddMenuItem("Usage…", "Show bucket usage")
+
+	addQueueMenu()
 
 	systray.AddSeparator()
 
 	mQuit := systray.AddMenuItem("Quit", "Quit the application")
 
+	startWatch()
+
+	configChanged, err := watchConfig()
+	if err != nil {
+		showError(fmt.Sprintf("Config changes will not be picked up until restart: %v", err))
+	}
+
 	// Auto-start if configured
 	if cfg.Mount.AutoStart {
 		go func() {
@@ -90,6 +115,10 @@ func onReady() {
 
 	// Handle menu clicks
 	go func() {
+		remoteTicker := time.NewTicker(remoteCheckInterval)
+		defer remoteTicker.Stop()
+		remoteChanged := make(chan struct{}, 1)
+
 		for {
 			select {
 			case <-mStart.ClickedCh:
@@ -100,6 +129,23 @@ func onReady() {
 				if err := stopMount(mStart, mStop, mStatus, mInfo); err != nil {
 					showError(fmt.Sprintf("Stop failed: %v", err))
 				}
+			case i := <-profiles.clicked:
+				if name := profiles.name(i); name != "" {
+					if err := switchProfile(name, mStart, mStop, mStatus, mInfo, mType); err != nil {
+						showError(fmt.Sprintf("Profile switch failed: %v", err))
+					}
+				}
+				// Keep the check on the profile in use, also after a failed switch
+				active, _ := current()
+				profiles.update(active)
+			case <-mUsage.ClickedCh:
+				go showUsage()
+			case <-remoteTicker.C:
+				refreshRemote(remoteChanged)
+			case <-configChanged:
+				reloadConfig(mStart, mStop, mStatus, mInfo, mType)
+			case <-remoteChanged:
+				reloadConfig(mStart, mStop, mStatus, mInfo, mType)
 			case <-mQuit.ClickedCh:
 				systray.Quit()
 				return
@@ -109,7 +155,8 @@ func onReady() {
 }
 
 func onExit() {
-	if manager != nil {
+	if s := running.Load(); s != nil {
+		cfg, manager := s.cfg, s.manager
 		if cfg.IsWinFsp() {
 			_ = manager.UnmountWinFsp()
 		} else {
@@ -119,7 +166,48 @@ func onExit() {
 	}
 }
 
+// updateTitles sets the tray title, tooltip and mode item for the current config
+func updateTitles(mType *systray.MenuItem) {
+	cfg, _ := current()
+	mountType := "WebDAV"
+	if cfg.IsWinFsp() {
+		mountType = "WinFsp"
+		systray.SetTitle("MinIO Mount")
+		systray.SetTooltip("MinIO Cloud Drive (WinFsp)")
+	} else {
+		systray.SetTitle("MinIO WebDAV")
+		systray.SetTooltip("MinIO Cloud WebDAV Server")
+	}
+
+	if name := cfg.ActiveProfile(); name != "" {
+		mType.SetTitle(fmt.Sprintf("Mode: %s (%s)", mountType, name))
+	} else {
+		mType.SetTitle(fmt.Sprintf("Mode: %s", mountType))
+	}
+}
+
+// switchProfile loads the named profile and applies it, reconnecting the
+// drive if it was running
+func switchProfile(name string, mStart, mStop, mStatus, mInfo, mType *systray.MenuItem) error {
+	if cfg, _ := current(); name == cfg.ActiveProfile() {
+		return nil
+	}
+
+	newCfg, err := config.LoadProfile(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = applyConfig(newCfg, mStart, mStop, mStatus, mInfo, mType)
+	return err
+}
+
 func startMount(mStart, mStop, mStatus, mInfo *systray.MenuItem) error {
+	cfg, manager := current()
+	if err := checkBucket(cfg); err != nil {
+		return err
+	}
+
 	if cfg.IsWinFsp() {
 		// WinFsp mount
 		if err := manager.MountWinFsp(); err != nil {
@@ -165,6 +253,7 @@ func startMount(mStart, mStop, mStatus, mInfo *systray.MenuItem) error {
 }
 
 func stopMount(mStart, mStop, mStatus, mInfo *systray.MenuItem) error {
+	cfg, manager := current()
 	if cfg.IsWinFsp() {
 		// WinFsp unmount
 		if err := manager.UnmountWinFsp(); err != nil {
@@ -191,6 +280,64 @@ func stopMount(mStart, mStop, mStatus, mInfo *systray.MenuItem) error {
 	return nil
 }
 
+// checkBucket bootstraps the bucket and checks that it can be listed
+// before mounting, so access problems are reported with what to do about
+// them. Nothing is written; minioctl probe tests writing on demand. An
+// unreachable server is left to rclone, which retries on its own.
+func checkBucket(cfg *config.Config) error {
+	client, err := minio.NewClient(&cfg.MinIO)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	bucketErr := client.EnsureBucket(ctx)
+	if errors.Is(bucketErr, minio.ErrBucketMissing) {
+		return bucketErr
+	}
+
+	report := client.CheckAccess(ctx)
+	if !report.Reachable {
+		return nil
+	}
+	if err := report.MountError(); err != nil {
+		if bucketErr != nil && !report.BucketExists {
+			return bucketErr // says why it could not be created
+		}
+		return err
+	}
+	return nil
+}
+
+// showUsage walks the bucket and shows the totals as a notification
+func showUsage() {
+	cfg, _ := current()
+	client, err := minio.NewClient(&cfg.MinIO)
+	if err != nil {
+		showError(fmt.Sprintf("Usage failed: %v", err))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	report, err := client.Usage(ctx, "")
+	if err != nil {
+		showError(fmt.Sprintf("Usage failed: %v", err))
+		return
+	}
+
+	msg := fmt.Sprintf("%s: %s in %d objects",
+		report.Bucket, humanize.IBytes(uint64(report.Bytes)), report.Objects)
+	if len(report.Folders) > 0 {
+		top := report.Folders[0]
+		msg += fmt.Sprintf("\nLargest folder: %s (%s)", top.Name, humanize.IBytes(uint64(top.Bytes)))
+	}
+	_ = beeep.Notify("MinIO Usage", msg, "")
+}
+
 func showError(msg string) {
 	_ = beeep.Alert("MinIO Error", msg, "")
 }
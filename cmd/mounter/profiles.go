@@ -0,0 +1,73 @@
+package main
+
+import (
+	"simple-uploader/internal/config"
+
+	"github.com/getlantern/systray"
+)
+
+// profileMenu is the profile submenu, one checkbox per profile. Entries are
+// reused and hidden when profiles are removed, since systray cannot remove
+// items.
+type profileMenu struct {
+	menu    *systray.MenuItem
+	items   []*systray.MenuItem
+	names   []string
+	clicked chan int // index of the clicked entry
+}
+
+// profiles is the profile submenu; it is only used on the menu goroutine
+var profiles *profileMenu
+
+// addProfileMenu adds the profile submenu for the running config
+func addProfileMenu() {
+	profiles = &profileMenu{
+		menu:    systray.AddMenuItem("Profile", "Switch profile"),
+		clicked: make(chan int),
+	}
+	cfg, _ := current()
+	profiles.update(cfg)
+}
+
+// name returns the profile shown by entry i, or "" for a click on an entry
+// hidden since
+func (m *profileMenu) name(i int) string {
+	if i >= len(m.names) {
+		return ""
+	}
+	return m.names[i]
+}
+
+// update lists the profiles of cfg and checks the active one, hiding the
+// submenu when there are none
+func (m *profileMenu) update(cfg *config.Config) {
+	m.names = cfg.ProfileNames()
+	for i, name := range m.names {
+		if i == len(m.items) {
+			item := m.menu.AddSubMenuItemCheckbox("", "", false)
+			m.items = append(m.items, item)
+			go func(i int) {
+				for range item.ClickedCh {
+					m.clicked <- i
+				}
+			}(i)
+		}
+		m.items[i].SetTitle(name)
+		m.items[i].SetTooltip("Use profile " + name)
+		if name == cfg.ActiveProfile() {
+			m.items[i].Check()
+		} else {
+			m.items[i].Uncheck()
+		}
+		m.items[i].Show()
+	}
+	for _, item := range m.items[len(m.names):] {
+		item.Hide()
+	}
+
+	if len(m.names) == 0 {
+		m.menu.Hide()
+	} else {
+		m.menu.Show()
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+	"simple-uploader/internal/queue"
+
+	"github.com/gen2brain/beeep"
+	"github.com/getlantern/systray"
+)
+
+// queueMenu is the queued uploads item and its retry item, shown while the
+// offline upload queue is enabled
+type queueMenu struct {
+	mQueue   *systray.MenuItem
+	mRetry   *systray.MenuItem
+	settings config.QueueConfig
+	stop     func() // stops the drainer, nil while the queue is off
+}
+
+// queued is the queue menu; it is only used on the menu goroutine
+var queued *queueMenu
+
+// addQueueMenu adds the queue items and starts the drainer if the running
+// config enables the queue
+func addQueueMenu() {
+	queued = &queueMenu{
+		mQueue: systray.AddMenuItem("Queued uploads: 0", "Uploads waiting for the server"),
+		mRetry: systray.AddMenuItem("Retry Queued Uploads", "Retry queued uploads now"),
+	}
+	queued.mQueue.Disable()
+	queued.mRetry.Disable()
+	cfg, _ := current()
+	queued.update(cfg)
+}
+
+// update restarts the drainer when the queue settings of cfg differ from
+// the ones it runs with, and hides the items while the queue is off
+func (m *queueMenu) update(cfg *config.Config) {
+	if m.stop != nil && reflect.DeepEqual(m.settings, cfg.Queue) {
+		return
+	}
+	if m.stop != nil {
+		m.stop()
+		m.stop = nil
+	}
+	m.settings = cfg.Queue
+
+	if !cfg.Queue.Enabled {
+		m.mQueue.Hide()
+		m.mRetry.Hide()
+		return
+	}
+	m.mQueue.Show()
+	m.mRetry.Show()
+	m.stop = startQueue(&cfg.Queue, m.mQueue, m.mRetry)
+}
+
+// startQueue hosts the offline upload queue drainer and keeps the tray
+// item showing the number of pending uploads up to date. The returned
+// function stops both and waits for them.
+func startQueue(settings *config.QueueConfig, mQueue, mRetry *systray.MenuItem) func() {
+	q, err := queue.OpenFromConfig(settings)
+	if err != nil {
+		showError(fmt.Sprintf("Upload queue unavailable: %v", err))
+		return func() {}
+	}
+
+	interval := time.Duration(settings.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	drainer := &queue.Drainer{
+		Queue:       q,
+		Upload:      uploadQueued,
+		Reachable:   reachable,
+		Interval:    interval,
+		MaxAttempts: settings.MaxAttempts,
+		OnUploaded: func(item queue.Item) {
+			_ = beeep.Notify("Upload Complete",
+				fmt.Sprintf("Uploaded queued file: %s", filepath.Base(item.Path)), "")
+		},
+		OnGiveUp: func(item queue.Item) {
+			showError(fmt.Sprintf("Giving up on queued upload %s: %s", filepath.Base(item.Path), item.LastError))
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		drainer.Run(ctx)
+	}()
+
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(10 * time.Second)
+		defer ticker.Stop()
+		for {
+			if items, err := q.List(); err == nil {
+				mQueue.SetTitle(fmt.Sprintf("Queued uploads: %d", len(items)))
+				if len(items) > 0 {
+					mRetry.Enable()
+				} else {
+					mRetry.Disable()
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-mRetry.ClickedCh:
+				// Retry everything now, ignoring the backoff and
+				// refusals that may have been fixed meanwhile
+				if items, err := q.List(); err == nil {
+					for _, item := range items {
+						item.NextAttempt = time.Time{}
+						item.GivenUp = false
+						_ = q.Update(item)
+					}
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					drainer.Drain(ctx)
+				}()
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// uploadQueued uploads one queued item with the settings of its profile
+func uploadQueued(ctx context.Context, item queue.Item) error {
+	itemCfg, err := config.LoadProfile(item.Profile)
+	if err != nil {
+		return err
+	}
+
+	client, err := minio.NewClient(&itemCfg.MinIO)
+	if err != nil {
+		return err
+	}
+
+	opts := minio.OptionsFromConfig(itemCfg).
+		WithMetadata(item.Metadata).
+		WithTags(item.Tags).
+		WithStorageClass(item.StorageClass)
+	if err := client.SetUploadOptions(opts); err != nil {
+		return err
+	}
+
+	if timeout := itemCfg.Upload.TimeoutFor(item.Size); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	return client.UploadFileAs(ctx, item.Source(), item.Key)
+}
+
+// reachable reports whether the endpoint of a profile answers
+func reachable(ctx context.Context, profile string) bool {
+	profileCfg, err := config.LoadProfile(profile)
+	if err != nil {
+		return false
+	}
+
+	client, err := minio.NewClient(&profileCfg.MinIO)
+	if err != nil {
+		return false
+	}
+
+	pingCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	err = client.Ping(pingCtx)
+	return err == nil || !minio.IsOffline(err)
+}
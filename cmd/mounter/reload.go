@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/rclone"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gen2brain/beeep"
+	"github.com/getlantern/systray"
+)
+
+// reloadDelay lets an editor finish writing before the config is read
+const reloadDelay = 500 * time.Millisecond
+
+// watchConfig signals on the returned channel when a config file or the
+// administrator policy changes. The folders are watched rather than the
+// files, since editors often save by replacing the file.
+func watchConfig() (<-chan struct{}, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := config.Files()
+	if err != nil {
+		fw.Close()
+		return nil, err
+	}
+	paths := []string{config.AdminPolicyPath()}
+	for _, f := range files {
+		paths = append(paths, f.Source)
+	}
+
+	watched := make(map[string]bool)
+	for _, p := range paths {
+		watched[strings.ToLower(filepath.Clean(p))] = true
+		dir := filepath.Dir(p)
+		if _, err := os.Stat(dir); err == nil {
+			_ = fw.Add(dir)
+		}
+	}
+
+	changed := make(chan struct{}, 1)
+	go func() {
+		defer fw.Close()
+
+		var debounce *time.Timer
+		for {
+			select {
+			case event, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if !watched[strings.ToLower(filepath.Clean(event.Name))] {
+					continue
+				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(reloadDelay, func() {
+					select {
+					case changed <- struct{}{}:
+					default:
+					}
+				})
+			case _, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+			}
+		}
+	}()
+
+	return changed, nil
+}
+
+// reloadConfig reads the edited config and applies it. An invalid edit is
+// rejected and the running settings stay in effect.
+func reloadConfig(mStart, mStop, mStatus, mInfo, mType *systray.MenuItem) {
+	cfg, _ := current()
+	newCfg, err := config.LoadProfile(cfg.ActiveProfile())
+	if err != nil {
+		_ = beeep.Alert("MinIO Drive", fmt.Sprintf("Config change rejected, keeping the current settings:\n%v", err), "")
+		return
+	}
+	if reflect.DeepEqual(cfg, newCfg) {
+		return // saved without changes, or rewritten by a format upgrade
+	}
+
+	restarted, err := applyConfig(newCfg, mStart, mStop, mStatus, mInfo, mType)
+	if err != nil {
+		showError(fmt.Sprintf("Failed to apply config change: %v", err))
+		return
+	}
+	if restarted {
+		_ = beeep.Notify("MinIO Drive", "Config reloaded, drive reconnected", "")
+	} else {
+		_ = beeep.Notify("MinIO Drive", "Config reloaded", "")
+	}
+}
+
+// applyConfig makes newCfg the running config. rclone is restarted only
+// when a setting it uses changed; everything else, including the menus for
+// locks, profiles and the upload queue, applies live. It reports whether
+// the drive was restarted.
+func applyConfig(newCfg *config.Config, mStart, mStop, mStatus, mInfo, mType *systray.MenuItem) (bool, error) {
+	cfg, manager := current()
+	restart := needsRestart(cfg, newCfg)
+
+	newManager := manager
+	if restart || !(manager.IsRunning() || manager.IsMounted()) {
+		m, err := rclone.NewManager(newCfg)
+		if err != nil {
+			return false, err
+		}
+		newManager = m
+	}
+
+	wasRunning := restart && (manager.IsRunning() || manager.IsMounted())
+	if wasRunning {
+		if err := stopMount(mStart, mStop, mStatus, mInfo); err != nil {
+			return false, err
+		}
+	}
+
+	running.Store(&session{cfg: newCfg, manager: newManager})
+	updateTitles(mType)
+	locked.update(newCfg)
+	profiles.update(newCfg)
+	queued.update(newCfg)
+	startWatch()
+
+	if wasRunning {
+		return true, startMount(mStart, mStop, mStatus, mInfo)
+	}
+	return false, nil
+}
+
+// needsRestart reports whether rclone must be restarted to pick up the
+// difference between two configs: the server, bucket or credentials, the
+// mount type, port or drive letter, or the filter rules
+func needsRestart(old, new *config.Config) bool {
+	if !reflect.DeepEqual(old.MinIO, new.MinIO) || !reflect.DeepEqual(old.Filters, new.Filters) {
+		return true
+	}
+	oldMount, newMount := old.Mount, new.Mount
+	oldMount.AutoStart, newMount.AutoStart = false, false
+	return oldMount != newMount
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"simple-uploader/internal/config"
+)
+
+// remoteCheckInterval is how often the mounter checks whether the remote
+// config is due for a refresh
+const remoteCheckInterval = time.Minute
+
+// remoteFetchTimeout bounds one background fetch of the remote config
+const remoteFetchTimeout = time.Minute
+
+// remoteFetching is set while a fetch runs, so a slow server does not pile
+// up fetches
+var remoteFetching atomic.Bool
+
+// refreshRemote fetches the remote config in the background when it is due
+// and signals changed when a new version arrived. Failures are expected
+// while offline and leave the last good copy in effect, so they are not
+// shown.
+func refreshRemote(changed chan<- struct{}) {
+	cfg, _ := current()
+	if cfg.Remote.URL == "" || !remoteFetching.CompareAndSwap(false, true) {
+		return
+	}
+	remote := cfg.Remote
+
+	go func() {
+		defer remoteFetching.Store(false)
+
+		ctx, cancel := context.WithTimeout(context.Background(), remoteFetchTimeout)
+		defer cancel()
+		if ok, err := config.RefreshRemote(ctx, remote); err == nil && ok {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		}
+	}()
+}
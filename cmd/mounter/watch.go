@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+	"simple-uploader/internal/watch"
+
+	"github.com/gen2brain/beeep"
+)
+
+// stopWatch stops the running drop folder watcher, if any
+var stopWatch context.CancelFunc
+
+// startWatch starts uploading files dropped into the configured watch
+// folders, replacing a watcher started for a previous profile
+func startWatch() {
+	if stopWatch != nil {
+		stopWatch()
+		stopWatch = nil
+	}
+
+	cfg, _ := current()
+	if len(cfg.Watch.Rules) == 0 {
+		return
+	}
+
+	client, err := minio.NewClient(&cfg.MinIO)
+	if err != nil {
+		showError(fmt.Sprintf("Drop folders unavailable: %v", err))
+		return
+	}
+	if err := client.SetUploadOptions(minio.OptionsFromConfig(cfg)); err != nil {
+		showError(fmt.Sprintf("Drop folders unavailable: %v", err))
+		return
+	}
+
+	notify := cfg.Watch.Notify
+	w := &watch.Watcher{
+		Client:    client,
+		Rules:     cfg.Watch.Rules,
+		StableFor: time.Duration(cfg.Watch.StableSeconds) * time.Second,
+		Filters:   cfg.Filters,
+		OnUploaded: func(rule config.WatchRule, filePath, key string) {
+			if notify {
+				_ = beeep.Notify("Upload Complete", fmt.Sprintf("Uploaded %s to %s", filepath.Base(filePath), key), "")
+			}
+		},
+		OnError: func(filePath string, err error) {
+			if filePath != "" {
+				showError(fmt.Sprintf("Drop folder upload failed for %s: %v", filepath.Base(filePath), err))
+			}
+		},
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stopWatch = cancel
+	go func() {
+		if err := w.Run(ctx); err != nil {
+			showError(fmt.Sprintf("Drop folder watcher stopped: %v", err))
+		}
+	}()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
@@ -8,11 +9,15 @@ import (
 	"time"
 
 	"simple-uploader/internal/config"
+	"simple-uploader/internal/rclone"
 )
 
 func main() {
 	fmt.Println("=== MinIO Mounter Debug ===")
 
+	profile := config.AddFlags(flag.CommandLine)
+	flag.Parse()
+
 	// Check executable path
 	exePath, err := os.Executable()
 	if err != nil {
@@ -35,7 +40,7 @@ func main() {
 
 	// Load config
 	fmt.Println("\n[2] Loading config...")
-	cfg, err := config.Load()
+	cfg, err := config.LoadProfile(*profile)
 	if err != nil {
 		fmt.Printf("ERROR loading config: %v\n", err)
 		waitExit()
@@ -46,31 +51,22 @@ func main() {
 	if mountType == "" {
 		mountType = "webdav"
 	}
-	fmt.Printf("Config: endpoint=%s, bucket=%s, type=%s, port=%d, drive=%s\n",
-		cfg.MinIO.Endpoint, cfg.MinIO.Bucket, mountType, cfg.Mount.Port, cfg.Mount.DriveLetter)
+	fmt.Printf("Config: profile=%q, endpoint=%s, bucket=%s, type=%s, port=%d, drive=%s\n",
+		cfg.ActiveProfile(), cfg.MinIO.Endpoint, cfg.MinIO.Bucket, mountType, cfg.Mount.Port, cfg.Mount.DriveLetter)
 
 	// Generate rclone config
 	fmt.Println("\n[3] Generating rclone config...")
 	rcloneConfigPath := filepath.Join(exeDir, "rclone.conf")
 
-	protocol := "http"
-	if cfg.MinIO.UseSSL {
-		protocol = "https"
+	configContent, secretEnv, expires, err := rclone.RenderConfig(&cfg.MinIO)
+	if err != nil {
+		fmt.Printf("ERROR getting credentials: %v\n", err)
+		waitExit()
+		return
+	}
+	if !expires.IsZero() {
+		fmt.Printf("Credentials from credential_process expire at %s\n", expires.Format(time.RFC3339))
 	}
-
-	configContent := fmt.Sprintf(`[minio]
-type = s3
-provider = Minio
-access_key_id = %s
-secret_access_key = %s
-endpoint = %s://%s
-force_path_style = true
-`,
-		cfg.MinIO.AccessKey,
-		cfg.MinIO.SecretKey,
-		protocol,
-		cfg.MinIO.Endpoint,
-	)
 
 	if err := os.WriteFile(rcloneConfigPath, []byte(configContent), 0600); err != nil {
 		fmt.Printf("ERROR writing rclone config: %v\n", err)
@@ -81,7 +77,10 @@ force_path_style = true
 
 	// Test rclone connection
 	fmt.Println("\n[4] Testing rclone connection...")
+	// The secret key is passed in the environment, not in rclone.conf
+	rcloneEnv := append(os.Environ(), secretEnv...)
 	cmd := exec.Command(rclonePath, "--config", rcloneConfigPath, "lsd", "minio:")
+	cmd.Env = rcloneEnv
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		fmt.Printf("ERROR listing buckets: %v\n", err)
@@ -116,6 +115,7 @@ force_path_style = true
 			remotePath,
 			driveLetter,
 		)
+		mountCmd.Env = rcloneEnv
 		mountCmd.Stdout = os.Stdout
 		mountCmd.Stderr = os.Stderr
 
@@ -151,6 +151,7 @@ force_path_style = true
 			"--addr", addr,
 			remotePath,
 		)
+		serveCmd.Env = rcloneEnv
 		serveCmd.Stdout = os.Stdout
 		serveCmd.Stderr = os.Stderr
 
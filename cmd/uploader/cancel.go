@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"sync"
+	"time"
+
+	"simple-uploader/internal/icon"
+
+	"github.com/getlantern/systray"
+)
+
+// errCancelled is the cause of uploads stopped by the user
+var errCancelled = errors.New("upload cancelled")
+
+// trayDelay keeps the tray icon away for uploads that finish quickly
+const trayDelay = 2 * time.Second
+
+// cancelOnInterrupt cancels the uploads on Ctrl+C when run from a console
+func cancelOnInterrupt(cancel context.CancelCauseFunc) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel(errCancelled)
+	}()
+}
+
+// showCancelTray shows a tray icon with a Cancel item once the uploads have
+// run for trayDelay. The returned function removes it again.
+func showCancelTray(cancel context.CancelCauseFunc) (stop func()) {
+	var mu sync.Mutex
+	var stopped bool
+	var ready chan struct{}
+
+	timer := time.AfterFunc(trayDelay, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		ready = make(chan struct{})
+		go runCancelTray(cancel, ready)
+	})
+
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if stopped {
+			return
+		}
+		stopped = true
+		timer.Stop()
+		if ready == nil {
+			return
+		}
+
+		// Quit only works once the tray window exists
+		select {
+		case <-ready:
+			systray.Quit()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func runCancelTray(cancel context.CancelCauseFunc, ready chan struct{}) {
+	// The tray window must be serviced by the thread that created it
+	runtime.LockOSThread()
+
+	systray.Run(func() {
+		systray.SetIcon(icon.Data)
+		systray.SetTooltip("MinIO Drive: uploading")
+		mCancel := systray.AddMenuItem("Cancel Upload", "Stop the upload in progress")
+		close(ready)
+
+		<-mCancel.ClickedCh
+		mCancel.SetTitle("Cancelling...")
+		mCancel.Disable()
+		cancel(errCancelled)
+	}, nil)
+}
@@ -2,75 +2,395 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"simple-uploader/internal/config"
+	"simple-uploader/internal/filter"
 	"simple-uploader/internal/minio"
+	"simple-uploader/internal/policy"
 
+	"github.com/dustin/go-humanize"
 	"github.com/gen2brain/beeep"
 )
 
+// stdinSource is the source argument that reads from standard input
+const stdinSource = "-"
+
+// metaFlags collects repeated key=value flags such as -meta and -tag
+type metaFlags map[string]string
+
+func (m metaFlags) String() string { return fmt.Sprint(map[string]string(m)) }
+
+func (m metaFlags) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("expected key=value, got %q", value)
+	}
+	m[k] = v
+	return nil
+}
+
 func main() {
-	if len(os.Args) < 2 {
-		showNotification("Upload Error", "No files specified")
-		os.Exit(1)
+	meta := metaFlags{}
+	tags := metaFlags{}
+	profile := config.AddFlags(flag.CommandLine)
+	key := flag.String("key", "", "object key (required when uploading from stdin)")
+	partSize := flag.String("part-size", "", "multipart part size, e.g. 64MiB")
+	progress := flag.Bool("progress", false, "print upload progress to stderr")
+	asJSON := flag.Bool("json", false, "print a JSON report to stdout")
+	reportPath := flag.String("report", "", "write a JSON report to this file")
+	storageClass := flag.String("storage-class", "", "storage class for the uploaded objects")
+	flag.Var(meta, "meta", "user metadata key=value (repeatable)")
+	flag.Var(tags, "tag", "object tag key=value, merged over the configured tags (repeatable)")
+	flag.Parse()
+
+	rep := &report{StartedAt: time.Now()}
+
+	// exit writes the report, when one was asked for, and ends the process
+	exit := func(code int) {
+		if *asJSON || *reportPath != "" {
+			if err := rep.write(*asJSON, *reportPath); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		os.Exit(code)
+	}
+
+	// fatal reports an error that stops the whole upload
+	fatal := func(msg string) {
+		showNotification("Upload Error", msg)
+		rep.Error = msg
+		exit(rep.finish())
+	}
+
+	if flag.NArg() < 1 {
+		fatal("No files specified")
 	}
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadProfile(*profile)
 	if err != nil {
-		showNotification("Upload Error", fmt.Sprintf("Failed to load config: %v", err))
-		os.Exit(1)
+		fatal(fmt.Sprintf("Failed to load config: %v", err))
 	}
+	rep.Profile = cfg.ActiveProfile()
+	rep.Bucket = cfg.MinIO.Bucket
 
 	// Create MinIO client
 	client, err := minio.NewClient(&cfg.MinIO)
 	if err != nil {
-		showNotification("Upload Error", fmt.Sprintf("Failed to connect: %v", err))
-		os.Exit(1)
+		fatal(fmt.Sprintf("Failed to connect: %v", err))
+	}
+
+	opts := minio.OptionsFromConfig(cfg).
+		WithMetadata(meta).
+		WithTags(tags).
+		WithStorageClass(*storageClass)
+	if *partSize != "" {
+		size, err := humanize.ParseBytes(*partSize)
+		if err != nil {
+			fatal(fmt.Sprintf("Invalid part size: %v", err))
+		}
+		opts.PartSize = size
+	}
+	if *progress {
+		opts.Progress = printProgress
+	}
+
+	if err := client.SetUploadOptions(opts); err != nil {
+		fatal(fmt.Sprintf("Invalid upload settings: %v", err))
+	}
+
+	// Get sources from arguments
+	sources := flag.Args()
+	if *key != "" && len(sources) > 1 {
+		fatal("-key can only be used with a single source")
 	}
 
-	// Get file paths from arguments
-	filePaths := os.Args[1:]
+	// Validate sources exist; stdin and named pipes are streamed. Files
+	// excluded by filter rules or a .minioignore next to them are skipped.
+	var pending []*fileResult
+	for _, path := range sources {
+		r := &fileResult{Source: path, Key: objectKey(path, *key)}
+		rep.Files = append(rep.Files, r)
+
+		if path == stdinSource {
+			if *key == "" {
+				fatal("-key is required when uploading from stdin")
+			}
+			r.stream = true
+			pending = append(pending, r)
+			continue
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			r.fail(statusFailed, err)
+			continue
+		}
+		if info.IsDir() {
+			r.Status, r.Error = statusSkipped, "folders are not uploaded"
+			continue
+		}
+		if info.Mode().IsRegular() {
+			r.Size = info.Size()
+		} else {
+			r.stream = true
+		}
+		if filter.ExcludedFile(path, cfg.Filters) {
+			r.Status, r.Error = statusSkipped, "excluded by filter rules"
+			continue
+		}
+		pending = append(pending, r)
+	}
 
-	// Validate files exist
-	var validFiles []string
-	for _, path := range filePaths {
-		if info, err := os.Stat(path); err == nil && !info.IsDir() {
-			validFiles = append(validFiles, path)
+	if skipped := countStatus(rep.Files, statusSkipped); skipped > 0 && cfg.Upload.ShowNotification {
+		showNotification("Upload Skipped", fmt.Sprintf("%d file(s) were not uploaded", skipped))
+	}
+	if len(pending) == 0 {
+		if countStatus(rep.Files, statusFailed) > 0 {
+			showNotification("Upload Error", "No valid files to upload")
 		}
+		exit(rep.finish())
 	}
 
-	if len(validFiles) == 0 {
-		showNotification("Upload Error", "No valid files to upload")
-		os.Exit(1)
+	// Enforce the upload policy before anything is sent
+	pol, err := policy.New(&cfg.Policy)
+	if err != nil {
+		fatal(fmt.Sprintf("Invalid upload policy: %v", err))
+	}
+	pending, rejected := checkPolicy(pol, pending)
+	if len(rejected) > 0 {
+		var lines []string
+		for _, r := range rejected {
+			lines = append(lines, fmt.Sprintf("%s: %s", filepath.Base(r.Source), r.Error))
+			if !*asJSON {
+				fmt.Fprintf(os.Stderr, "rejected %s: %s\n", r.Source, r.Error)
+			}
+		}
+		showNotification("Upload Rejected", strings.Join(lines, "\n"))
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	// Uploads run until they finish, time out per file or are cancelled
+	// with Ctrl+C or from the tray
+	ctx, cancel := context.WithCancelCause(context.Background())
+	defer cancel(nil)
+	cancelOnInterrupt(cancel)
+	stopTray := showCancelTray(cancel)
+
+	// Upload files; sources that fail because the server is unreachable
+	// or busy are handed to the offline queue instead
+	var offline []*fileResult
 
 	// Ensure bucket exists
-	if err := client.EnsureBucket(ctx); err != nil {
-		showNotification("Upload Error", fmt.Sprintf("Bucket error: %v", err))
-		os.Exit(1)
+	bucketCtx, bucketCancel := context.WithTimeout(ctx, time.Minute)
+	err = client.EnsureBucket(bucketCtx)
+	bucketCancel()
+	if err != nil {
+		for _, r := range pending {
+			r.fail(statusFailed, err)
+		}
+		if !cfg.Queue.Enabled || ctx.Err() != nil || !minio.IsRetryable(err) {
+			stopTray()
+			fatal(fmt.Sprintf("Bucket error: %v", explainBucketError(ctx, client, err)))
+		}
+		offline = pending
+	} else {
+		for _, r := range pending {
+			if ctx.Err() != nil {
+				r.fail(statusFailed, context.Cause(ctx))
+				continue
+			}
+			upload(ctx, client, r, &cfg.Upload)
+			if r.Status == statusFailed && cfg.Queue.Enabled && ctx.Err() == nil && minio.IsRetryable(r.err) {
+				offline = append(offline, r)
+			}
+		}
+	}
+	stopTray()
+	if *progress {
+		fmt.Fprintln(os.Stderr)
 	}
 
-	// Upload files
-	successes, failures := client.UploadFiles(ctx, validFiles)
+	queued := enqueue(cfg, offline, meta, tags, *storageClass)
+	for _, r := range queued {
+		r.Status = statusQueued
+	}
 
 	// Show result notification
 	if cfg.Upload.ShowNotification {
-		showResult(successes, failures)
+		var successes []string
+		failures := make(map[string]error)
+		for _, r := range rep.Files {
+			switch r.Status {
+			case statusUploaded:
+				successes = append(successes, r.Source)
+			case statusFailed, statusRejected:
+				failures[r.Source] = r.err
+			}
+		}
+		if len(successes)+len(failures) > 0 {
+			showResult(successes, failures)
+		}
+		if len(queued) > 0 {
+			showNotification("Upload Queued",
+				fmt.Sprintf("%d file(s) will upload when the server is reachable", len(queued)))
+		}
 	}
 
-	if len(failures) > 0 {
-		os.Exit(1)
+	exit(rep.finish())
+}
+
+// checkPolicy splits sources into those the policy allows and those it
+// rejects. Rules on names apply to the object key; streams have no known
+// size and are held to the size limit while they are read.
+func checkPolicy(pol *policy.Policy, sources []*fileResult) (allowed, rejected []*fileResult) {
+	bySource := make(map[string]*fileResult, len(sources))
+	files := make([]policy.File, 0, len(sources))
+	for _, r := range sources {
+		bySource[r.Source] = r
+		files = append(files, policy.File{Path: r.Source, Name: r.Key, Size: r.Size})
 	}
+
+	accepted, rejections := pol.Check(files)
+	for _, f := range accepted {
+		allowed = append(allowed, bySource[f.Path])
+	}
+	for _, rej := range rejections {
+		r := bySource[rej.Path]
+		r.fail(statusRejected, rej.Err)
+		rejected = append(rejected, r)
+	}
+	return allowed, rejected
+}
+
+// upload sends one source and records the outcome. Each attempt may take
+// as long as the configured timeout allows for the file's size. Regular
+// files are tried again after network or server errors and stalls; streams
+// cannot be read twice.
+func upload(ctx context.Context, client *minio.Client, r *fileResult, uc *config.UploadConfig) {
+	start := time.Now()
+	defer func() { r.DurationMs = time.Since(start).Milliseconds() }()
+
+	size := r.Size
+	if r.stream {
+		size = -1
+	}
+	timeout := uc.TimeoutFor(size)
+
+	for attempt := 0; ; attempt++ {
+		info, err := attemptUpload(ctx, client, r, timeout)
+		if err == nil {
+			r.Status, r.Size, r.ETag = statusUploaded, info.Size, info.ETag
+			return
+		}
+		if ctx.Err() != nil {
+			r.fail(statusFailed, fmt.Errorf("%w: %v", context.Cause(ctx), err))
+			return
+		}
+		if attempt >= uc.Retries || r.stream || !minio.IsRetryable(err) {
+			r.fail(statusFailed, err)
+			return
+		}
+
+		r.Retries++
+		select {
+		case <-ctx.Done():
+			r.fail(statusFailed, context.Cause(ctx))
+			return
+		case <-time.After(time.Duration(attempt+1) * 2 * time.Second):
+		}
+	}
+}
+
+// attemptUpload runs put once within timeout, 0 meaning no limit
+func attemptUpload(ctx context.Context, client *minio.Client, r *fileResult, timeout time.Duration) (minio.UploadInfo, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	info, err := put(ctx, client, r.Source, r.Key)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return info, fmt.Errorf("timed out after %s: %w", timeout, context.DeadlineExceeded)
+	}
+	return info, err
+}
+
+// put uploads a source once. Regular files are uploaded with their size
+// known; stdin and other non-regular files such as named pipes are streamed.
+// The client holds both to the upload policy.
+func put(ctx context.Context, client *minio.Client, path, key string) (minio.UploadInfo, error) {
+	if path == stdinSource {
+		return client.PutStream(ctx, os.Stdin, key)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	if fi.Mode().IsRegular() {
+		return client.PutFile(ctx, path, key)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	defer f.Close()
+
+	return client.PutStream(ctx, f, key)
+}
+
+// explainBucketError probes the bucket to turn a failed bucket check into
+// an actionable message
+func explainBucketError(ctx context.Context, client *minio.Client, err error) error {
+	if errors.Is(err, minio.ErrBucketMissing) {
+		return err // already says what to do
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	if probeErr := client.Probe(probeCtx).UploadError(); probeErr != nil {
+		return probeErr
+	}
+	return err
+}
+
+// countStatus returns how many results have the given status
+func countStatus(results []*fileResult, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// objectKey returns the explicit key, or the file name for the bucket root
+func objectKey(path, key string) string {
+	if key != "" {
+		return key
+	}
+	return filepath.Base(path)
+}
+
+// progressMu keeps progress lines from parallel part uploads whole
+var progressMu sync.Mutex
+
+func printProgress(objectName string, sent int64) {
+	progressMu.Lock()
+	defer progressMu.Unlock()
+	fmt.Fprintf(os.Stderr, "\r%s: %s", objectName, humanize.IBytes(uint64(sent)))
 }
 
 func showNotification(title, message string) {
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/queue"
+)
+
+// enqueue adds copies of regular files to the offline queue so the mounter
+// can upload them later and returns those that were queued. Streams cannot
+// be replayed and stay failed, as do files that could not be queued, with
+// the reason added to their error.
+func enqueue(cfg *config.Config, results []*fileResult, meta, tags map[string]string, storageClass string) []*fileResult {
+	if len(results) == 0 {
+		return nil
+	}
+
+	q, err := queue.OpenFromConfig(&cfg.Queue)
+	if err != nil {
+		for _, r := range results {
+			r.fail(statusFailed, fmt.Errorf("%w; not queued: %v", r.err, err))
+		}
+		return nil
+	}
+
+	var queued []*fileResult
+	for _, r := range results {
+		info, err := os.Stat(r.Source)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+
+		item := queue.Item{
+			Path:         r.Source,
+			Key:          r.Key,
+			Profile:      cfg.ActiveProfile(),
+			Metadata:     meta,
+			Tags:         tags,
+			StorageClass: storageClass,
+		}
+		if _, err := q.Add(item); err != nil {
+			r.fail(statusFailed, fmt.Errorf("%w; not queued: %v", r.err, err))
+			continue
+		}
+
+		queued = append(queued, r)
+	}
+
+	return queued
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"simple-uploader/internal/minio"
+	"simple-uploader/internal/policy"
+)
+
+// Exit codes, so scripts can tell a partial failure from a total one
+const (
+	exitOK      = 0 // every file uploaded (or skipped by filters)
+	exitFailed  = 1 // nothing uploaded, or the upload could not start
+	exitPartial = 2 // some files uploaded, others failed, were rejected or queued
+)
+
+// File statuses in the report
+const (
+	statusUploaded = "uploaded"
+	statusFailed   = "failed"
+	statusRejected = "rejected" // refused by the upload policy
+	statusQueued   = "queued"   // server unreachable or busy, left for the mounter to retry
+	statusSkipped  = "skipped"  // excluded by filter rules
+)
+
+// fileResult is the outcome for one source
+type fileResult struct {
+	Source     string `json:"source"`
+	Key        string `json:"key"`
+	Size       int64  `json:"size"`
+	ETag       string `json:"etag,omitempty"`
+	Status     string `json:"status"`
+	DurationMs int64  `json:"duration_ms"`
+	Retries    int    `json:"retries"`
+	ErrorCode  string `json:"error_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+
+	err    error
+	stream bool // stdin or a pipe: size unknown, cannot be retried
+}
+
+// totals summarizes a batch
+type totals struct {
+	Files      int   `json:"files"`
+	Uploaded   int   `json:"uploaded"`
+	Failed     int   `json:"failed"`
+	Rejected   int   `json:"rejected"`
+	Queued     int   `json:"queued"`
+	Skipped    int   `json:"skipped"`
+	Bytes      int64 `json:"bytes"` // bytes uploaded
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// report is the machine-readable result written by -json and -report
+type report struct {
+	Profile   string        `json:"profile,omitempty"`
+	Bucket    string        `json:"bucket,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+	Files     []*fileResult `json:"files"`
+	Totals    totals        `json:"totals"`
+	ExitCode  int           `json:"exit_code"`
+	Error     string        `json:"error,omitempty"` // set when the upload could not start
+}
+
+// fail records err for a result, classifying it for the report
+func (r *fileResult) fail(status string, err error) {
+	r.Status = status
+	r.err = err
+	r.Error = err.Error()
+	if errors.Is(err, policy.ErrRejected) {
+		r.ErrorCode = "policy_rejected"
+	} else if errors.Is(err, errCancelled) {
+		r.ErrorCode = "canceled"
+	} else {
+		r.ErrorCode = minio.ErrorCode(err)
+	}
+}
+
+// finish computes the totals and the exit code
+func (rep *report) finish() int {
+	t := totals{Files: len(rep.Files), DurationMs: time.Since(rep.StartedAt).Milliseconds()}
+	for _, f := range rep.Files {
+		switch f.Status {
+		case statusUploaded:
+			t.Uploaded++
+			t.Bytes += f.Size
+		case statusFailed:
+			t.Failed++
+		case statusRejected:
+			t.Rejected++
+		case statusQueued:
+			t.Queued++
+		case statusSkipped:
+			t.Skipped++
+		}
+	}
+	rep.Totals = t
+
+	switch {
+	case rep.Error != "":
+		rep.ExitCode = exitFailed
+	case t.Failed+t.Rejected+t.Queued == 0:
+		rep.ExitCode = exitOK
+	case t.Uploaded == 0:
+		rep.ExitCode = exitFailed
+	default:
+		rep.ExitCode = exitPartial
+	}
+	return rep.ExitCode
+}
+
+// write prints the report to stdout and/or saves it to a file
+func (rep *report) write(toStdout bool, path string) error {
+	data, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if toStdout {
+		os.Stdout.Write(data)
+	}
+	if path != "" {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	return nil
+}
@@ -2,12 +2,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"time"
 
 	"simple-uploader/internal/config"
+	"simple-uploader/internal/filter"
 	"simple-uploader/internal/minio"
 )
 
@@ -15,16 +17,19 @@ func main() {
 	fmt.Println("=== Simple Uploader Debug ===")
 	fmt.Printf("Args: %v\n", os.Args)
 
-	if len(os.Args) < 2 {
+	profile := config.AddFlags(flag.CommandLine)
+	flag.Parse()
+
+	if flag.NArg() < 1 {
 		fmt.Println("ERROR: No files specified")
-		fmt.Println("Usage: uploader_debug.exe <file_path>")
+		fmt.Println("Usage: uploader_debug.exe [-profile name] <file_path>")
 		waitExit()
 		return
 	}
 
 	// Load configuration
 	fmt.Println("\n[1] Loading config...")
-	cfg, err := config.Load()
+	cfg, err := config.LoadProfile(*profile)
 	if err != nil {
 		fmt.Printf("ERROR loading config: %v\n", err)
 
@@ -35,7 +40,7 @@ func main() {
 		waitExit()
 		return
 	}
-	fmt.Printf("Config loaded: endpoint=%s, bucket=%s\n", cfg.MinIO.Endpoint, cfg.MinIO.Bucket)
+	fmt.Printf("Config loaded: profile=%q, endpoint=%s, bucket=%s\n", cfg.ActiveProfile(), cfg.MinIO.Endpoint, cfg.MinIO.Bucket)
 
 	// Create MinIO client
 	fmt.Println("\n[2] Connecting to MinIO...")
@@ -47,8 +52,17 @@ func main() {
 	}
 	fmt.Println("MinIO client created")
 
+	if err := client.SetUploadOptions(minio.OptionsFromConfig(cfg)); err != nil {
+		fmt.Printf("ERROR in upload settings: %v\n", err)
+		waitExit()
+		return
+	}
+	if cfg.Upload.Verify != "" {
+		fmt.Printf("Verify mode: %s\n", cfg.Upload.Verify)
+	}
+
 	// Get file paths
-	filePath := os.Args[1]
+	filePath := flag.Arg(0)
 	fmt.Printf("\n[3] File to upload: %s\n", filePath)
 
 	// Check file exists
@@ -64,10 +78,21 @@ func main() {
 		return
 	}
 	fmt.Printf("File size: %d bytes\n", info.Size())
+	if filter.ExcludedFile(filePath, cfg.Filters) {
+		fmt.Println("ERROR: File is excluded by filter rules or .minioignore")
+		waitExit()
+		return
+	}
 
-	// Create context
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	// Create context; Ctrl+C cancels the upload
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if timeout := cfg.Upload.TimeoutFor(info.Size()); timeout > 0 {
+		fmt.Printf("Timeout: %s, stall timeout: %ds\n", timeout, cfg.Upload.StallSeconds)
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
 	// Ensure bucket exists
 	fmt.Println("\n[4] Checking bucket...")
@@ -0,0 +1,286 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// LayerAdmin is the administrator policy, applied over every other layer
+const LayerAdmin = "admin"
+
+// defaultLockReason is shown when the policy gives no reason
+const defaultLockReason = "set by your administrator"
+
+// Lock is a setting the administrator policy pins
+type Lock struct {
+	Key    string
+	Value  string // JSON encoded effective value
+	Reason string
+}
+
+// adminPolicy is the policy file. A lock without a value pins the value
+// from the machine-wide config.json.
+//
+//	{
+//	  "reason": "Managed by IT, ext. 1234",
+//	  "locked": {
+//	    "minio.endpoint": { "value": "minio.corp:9000" },
+//	    "minio.use_ssl":  { "value": true, "reason": "TLS is required" },
+//	    "mount.type":     { "value": "webdav", "reason": "WinFsp is not approved" },
+//	    "upload.encryption": {}
+//	  }
+//	}
+type adminPolicy struct {
+	Reason string                `json:"reason"`
+	Locked map[string]adminEntry `json:"locked"`
+}
+
+type adminEntry struct {
+	Value  json.RawMessage `json:"value,omitempty"`
+	Reason string          `json:"reason,omitempty"`
+}
+
+// AdminPolicyPath returns the machine-wide policy file. It lives where only
+// administrators can write and cannot be moved by users.
+func AdminPolicyPath() string {
+	if runtime.GOOS == "windows" {
+		dir := os.Getenv("ProgramData")
+		if dir == "" {
+			dir = `C:\ProgramData`
+		}
+		return filepath.Join(dir, "MinIODrive", "policy.json")
+	}
+	return "/etc/minio-drive/policy.json"
+}
+
+// loadAdminPolicy reads the policy file, returning nil when there is none
+func loadAdminPolicy() (*adminPolicy, error) {
+	path := AdminPolicyPath()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read administrator policy: %w", err)
+	}
+
+	var p adminPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for key, e := range p.Locked {
+		t, err := KeyType(key)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(e.Value) > 0 {
+			if err := json.Unmarshal(e.Value, reflect.New(t).Interface()); err != nil {
+				return nil, fmt.Errorf("%s: locked.%s: %w", path, key, err)
+			}
+		}
+	}
+	return &p, nil
+}
+
+func (p *adminPolicy) reason(e adminEntry) string {
+	switch {
+	case e.Reason != "":
+		return e.Reason
+	case p.Reason != "":
+		return p.Reason
+	}
+	return defaultLockReason
+}
+
+// covering returns the locked key that path is or lies below, including
+// the copies of minio and mount locks in profiles
+func (p *adminPolicy) covering(path string) (string, bool) {
+	for key := range p.Locked {
+		if rest, ok := strings.CutPrefix(path, "profiles."); ok {
+			if _, sub, ok := strings.Cut(rest, "."); ok && (strings.HasPrefix(key, "minio.") || strings.HasPrefix(key, "mount.")) {
+				if sub == key || strings.HasPrefix(sub, key+".") {
+					return key, true
+				}
+			}
+		}
+		if path == key || strings.HasPrefix(path, key+".") {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// profilePaths returns the doc paths a top-level key stands for. A minio
+// or mount key also applies to every profile, so switching profiles
+// cannot get around a lock or an override.
+func profilePaths(doc map[string]any, key string) []string {
+	paths := []string{key}
+	section, _, _ := strings.Cut(key, ".")
+	if section != "minio" && section != "mount" {
+		return paths
+	}
+	profiles, _ := doc["profiles"].(map[string]any)
+	for name := range profiles {
+		paths = append(paths, "profiles."+name+"."+key)
+	}
+	return paths
+}
+
+// enforce applies the locked values to doc, overriding every other layer
+func (p *adminPolicy) enforce(doc, machine map[string]any, origins map[string]Origin) map[string]Lock {
+	locks := make(map[string]Lock, len(p.Locked))
+	origin := Origin{Layer: LayerAdmin, Source: AdminPolicyPath()}
+
+	keys := make([]string, 0, len(p.Locked))
+	for key := range p.Locked {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		e := p.Locked[key]
+		var value any
+		present := true
+		if len(e.Value) > 0 {
+			_ = json.Unmarshal(e.Value, &value)
+		} else {
+			value, present = lookup(machine, key)
+		}
+
+		for _, path := range profilePaths(doc, key) {
+			if present {
+				setValue(doc, path, value)
+			} else {
+				deleteValue(doc, path)
+			}
+			for o := range origins {
+				if o == path || strings.HasPrefix(o, path+".") {
+					delete(origins, o)
+				}
+			}
+			if present {
+				origins[path] = origin
+			}
+			encoded, _ := json.Marshal(value)
+			locks[path] = Lock{Key: path, Value: string(encoded), Reason: p.reason(e)}
+		}
+	}
+	return locks
+}
+
+// lookup returns the value at a dotted path in a raw document
+func lookup(doc map[string]any, path string) (any, bool) {
+	keys := strings.Split(path, ".")
+	m := doc
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		m = sub
+	}
+	v, ok := m[keys[len(keys)-1]]
+	return v, ok
+}
+
+// setValue stores a value at a dotted path, creating objects on the way
+func setValue(doc map[string]any, path string, v any) {
+	keys := strings.Split(path, ".")
+	m := doc
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			sub = map[string]any{}
+			m[k] = sub
+		}
+		m = sub
+	}
+	m[keys[len(keys)-1]] = v
+}
+
+// deleteValue removes the value at a dotted path, if present
+func deleteValue(doc map[string]any, path string) {
+	keys := strings.Split(path, ".")
+	m := doc
+	for _, k := range keys[:len(keys)-1] {
+		sub, ok := m[k].(map[string]any)
+		if !ok {
+			return
+		}
+		m = sub
+	}
+	delete(m, keys[len(keys)-1])
+}
+
+// LockedError is returned when a change touches a setting the
+// administrator policy pins
+type LockedError struct {
+	Lock Lock
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s is locked by the administrator policy (%s)", e.Lock.Key, e.Lock.Reason)
+}
+
+// Locked reports whether the setting at a config path is pinned by the
+// administrator policy. Paths below a locked key, such as one entry of a
+// locked map, are locked too.
+func (c *Config) Locked(path string) (Lock, bool) {
+	for p := c.KeyPath(path); p != ""; {
+		if l, ok := c.locks[p]; ok {
+			return l, true
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return Lock{}, false
+}
+
+// Locks returns the settings pinned by the administrator policy as they
+// apply to the sections in effect, sorted by key
+func (c *Config) Locks() []Lock {
+	var out []Lock
+	prefix := ""
+	if c.activeProfile != "" {
+		prefix = "profiles." + c.activeProfile + "."
+	}
+	for path, l := range c.locks {
+		switch {
+		case strings.HasPrefix(path, "profiles."):
+			if prefix == "" || !strings.HasPrefix(path, prefix) {
+				continue
+			}
+			l.Key = strings.TrimPrefix(path, prefix)
+		case prefix != "" && (strings.HasPrefix(path, "minio.") || strings.HasPrefix(path, "mount.")):
+			continue // the active profile's copy of the lock is listed instead
+		}
+		out = append(out, l)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// CheckUnlocked returns a *LockedError when the administrator policy pins
+// the setting at a config path, such as "profiles.work.minio.endpoint".
+// Editing a file cannot change a locked value, so edits are refused up
+// front rather than silently overridden.
+func CheckUnlocked(path string) error {
+	admin, err := loadAdminPolicy()
+	if err != nil || admin == nil {
+		return err
+	}
+	if key, ok := admin.covering(path); ok {
+		return &LockedError{Lock: Lock{Key: path, Reason: admin.reason(admin.Locked[key])}}
+	}
+	return nil
+}
@@ -2,28 +2,127 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 type MinIOConfig struct {
 	Endpoint  string `json:"endpoint"`
 	AccessKey string `json:"access_key"`
-	SecretKey string `json:"secret_key"`
-	Bucket    string `json:"bucket"`
-	UseSSL    bool   `json:"use_ssl"`
+	SecretKey string `json:"secret_key,omitempty"`
+	SecretRef string `json:"secret_ref,omitempty"` // "os:<name>" or "file:<name>", replaces secret_key
+	// Command printing short-lived keys as JSON, as for the AWS CLI;
+	// replaces access_key and secret_key
+	CredentialProcess string `json:"credential_process,omitempty"`
+	Bucket            string `json:"bucket"`
+	UseSSL            bool   `json:"use_ssl"`
+	Bootstrap         string `json:"bootstrap,omitempty"` // "create" (default), "require" or "skip"
 }
 
 type MountConfig struct {
-	Type        string `json:"type"`         // "webdav" or "winfsp"
-	Port        int    `json:"port"`         // WebDAV port (only for webdav)
+	Type        string `json:"type"` // "webdav" or "winfsp"
+	Port        int    `json:"port"` // WebDAV port (only for webdav)
 	DriveLetter string `json:"drive_letter"`
 	AutoStart   bool   `json:"auto_start"`
 }
 
+type UploadConfig struct {
+	ShowNotification bool              `json:"show_notification"`
+	Verify           string            `json:"verify"`        // "", "crc32c" or "sha256"
+	Metadata         map[string]string `json:"metadata"`      // user metadata added to every object
+	Encryption       string            `json:"encryption"`    // "" or "sse-s3"
+	PartSize         uint64            `json:"part_size"`     // multipart part size in bytes
+	Retries          int               `json:"retries"`       // extra attempts after a network or server error
+	StorageClass     string            `json:"storage_class"` // e.g. "REDUCED_REDUNDANCY", "" for the server default
+	Tags             map[string]string `json:"tags"`          // object tags set on every upload
+
+	// An upload may take TimeoutSeconds plus the time to send the file at
+	// MinSpeedKBps, and is cancelled when nothing is sent for StallSeconds
+	TimeoutSeconds int `json:"timeout_seconds"` // 0 for no overall limit
+	MinSpeedKBps   int `json:"min_speed_kbps"`
+	StallSeconds   int `json:"stall_seconds"` // 0 to wait forever
+}
+
+// TimeoutFor returns how long an upload of size bytes may take, or 0 when
+// there is no limit. Streams of unknown size pass a negative size and are
+// only bounded by the stall timeout.
+func (u *UploadConfig) TimeoutFor(size int64) time.Duration {
+	if u.TimeoutSeconds <= 0 || size < 0 {
+		return 0
+	}
+	timeout := time.Duration(u.TimeoutSeconds) * time.Second
+	if u.MinSpeedKBps > 0 {
+		timeout += time.Duration(size/(int64(u.MinSpeedKBps)*1024)) * time.Second
+	}
+	return timeout
+}
+
+type PolicyConfig struct {
+	MaxFileSize       uint64   `json:"max_file_size"`      // bytes, 0 for no limit
+	MaxBatchSize      uint64   `json:"max_batch_size"`     // total bytes per upload, 0 for no limit
+	AllowedExtensions []string `json:"allowed_extensions"` // when set, only these extensions may be uploaded
+	DeniedExtensions  []string `json:"denied_extensions"`
+	ForbiddenNames    []string `json:"forbidden_names"` // file name patterns such as "*password*"
+}
+
+type QueueConfig struct {
+	Enabled         bool   `json:"enabled"`          // queue uploads that fail while offline or busy
+	Dir             string `json:"dir"`              // defaults to %APPDATA%\MinIODrive\queue
+	IntervalSeconds int    `json:"interval_seconds"` // how often the mounter retries
+	MaxAttempts     int    `json:"max_attempts"`     // 0 retries forever
+}
+
+type WatchRule struct {
+	Folder     string `json:"folder"`      // local folder to watch
+	Prefix     string `json:"prefix"`      // destination prefix in the bucket
+	SentFolder string `json:"sent_folder"` // move uploaded files here; relative to folder, empty to leave them
+	Recursive  bool   `json:"recursive"`   // also watch subfolders
+
+	// Overrides of the upload section for files from this folder
+	StorageClass string            `json:"storage_class,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"` // merged over upload.tags
+}
+
+type WatchConfig struct {
+	StableSeconds int         `json:"stable_seconds"` // wait until a file is unchanged this long
+	Notify        bool        `json:"notify"`         // show a notification per uploaded file
+	Rules         []WatchRule `json:"rules"`
+}
+
+// RemoteConfig points at a centrally managed config.json. The file must
+// be signed with the private key matching PublicKey; the signature is
+// served next to it as <url>.sig.
+type RemoteConfig struct {
+	URL            string `json:"url"`
+	PublicKey      string `json:"public_key"`      // base64 Ed25519 public key
+	RefreshMinutes int    `json:"refresh_minutes"` // 0 for DefaultRemoteRefresh
+}
+
 type Config struct {
-	MinIO MinIOConfig `json:"minio"`
-	Mount MountConfig `json:"mount"`
+	Version int `json:"version"` // config format, see CurrentVersion
+
+	MinIO  MinIOConfig  `json:"minio"`
+	Mount  MountConfig  `json:"mount"`
+	Upload UploadConfig `json:"upload"`
+	Policy PolicyConfig `json:"policy"`
+	Queue  QueueConfig  `json:"queue"`
+	Watch  WatchConfig  `json:"watch"`
+	Remote RemoteConfig `json:"remote"`
+
+	// Filters are gitignore-style patterns for files never to upload,
+	// applied before the .minioignore files of each folder
+	Filters []string `json:"filters"`
+
+	DefaultProfile string             `json:"default_profile,omitempty"`
+	Profiles       map[string]Profile `json:"profiles,omitempty"`
+
+	activeProfile string            // profile selected by UseProfile
+	base          *Profile          // top-level sections before a profile was selected
+	origins       map[string]Origin // layer each value came from, by path
+	locks         map[string]Lock   // settings pinned by the administrator policy, by path
+	remote        *RemoteStatus     // remote config layer, nil without remote.url
 }
 
 // IsWebDAV returns true if mount type is webdav
@@ -36,7 +135,8 @@ func (c *Config) IsWinFsp() bool {
 	return c.Mount.Type == "winfsp"
 }
 
-// GetConfigPath returns the path to config.json next to the executable
+// GetConfigPath returns the path to the machine-wide config.json next to
+// the executable
 func GetConfigPath() (string, error) {
 	exePath, err := os.Executable()
 	if err != nil {
@@ -45,37 +145,154 @@ func GetConfigPath() (string, error) {
 	return filepath.Join(filepath.Dir(exePath), "config.json"), nil
 }
 
-// Load reads the configuration from config.json
+// Load reads the configuration layers: the machine-wide config.json next
+// to the executable, the remote config it points at, the user's
+// config.json, MINIODRIVE_* environment
+// variables and -set flags, each overriding the ones before it. Settings
+// locked by the administrator policy override them all. Variables in
+// names and locations such as minio.bucket are expanded last, see Expand.
 func Load() (*Config, error) {
-	configPath, err := GetConfigPath()
+	return load(nil, true)
+}
+
+// load reads the configuration layers, using pending instead of what is on
+// disk for the files in it. Without refresh the remote config is taken
+// from the cache as is.
+func load(pending map[string][]byte, refresh bool) (*Config, error) {
+	doc, origins, locks, remote, err := loadLayers(pending, refresh)
 	if err != nil {
 		return nil, err
 	}
-
-	data, err := os.ReadFile(configPath)
+	if err := expandDoc(doc); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
 	if err != nil {
 		return nil, err
 	}
 
-	var cfg Config
+	cfg := Config{
+		Upload: UploadConfig{
+			ShowNotification: true,
+			Retries:          2,
+			TimeoutSeconds:   300,
+			MinSpeedKBps:     128,
+			StallSeconds:     120,
+		},
+		Queue: QueueConfig{IntervalSeconds: 60},
+		Watch: WatchConfig{StableSeconds: 3, Notify: true},
+	}
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	cfg.Version = CurrentVersion
+	cfg.origins = origins
+	cfg.locks = locks
+	cfg.remote = remote
+	cfg.Normalize()
 
 	return &cfg, nil
 }
 
-// Save writes the configuration to config.json
+// Save writes the effective configuration to the user's config.json when
+// there is one, otherwise to the machine-wide file. When a profile is
+// active, its current settings are stored under that profile. An invalid
+// config is not saved, and the previous file is kept as config.json.bak.
+// The file is replaced atomically and is readable only by the current
+// user; a secret key resolved from secret_ref is not written back.
 func (c *Config) Save() error {
-	configPath, err := GetConfigPath()
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	configPath, err := savePath()
 	if err != nil {
 		return err
 	}
 
-	data, err := json.MarshalIndent(c, "", "  ")
+	out := *c
+	out.Version = CurrentVersion
+	if c.activeProfile != "" {
+		out.Profiles = make(map[string]Profile, len(c.Profiles))
+		for name, p := range c.Profiles {
+			out.Profiles[name] = p
+		}
+		out.Profiles[c.activeProfile] = Profile{MinIO: c.MinIO, Mount: c.Mount}
+		out.MinIO = c.base.MinIO
+		out.Mount = c.base.Mount
+	}
+
+	// Secrets resolved from a store are never written back
+	out.MinIO.dropResolvedSecret()
+	if out.Profiles != nil {
+		profiles := make(map[string]Profile, len(out.Profiles))
+		for name, p := range out.Profiles {
+			p.MinIO.dropResolvedSecret()
+			profiles[name] = p
+		}
+		out.Profiles = profiles
+	}
+
+	data, err := json.MarshalIndent(&out, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := backupFile(configPath); err != nil {
+		return err
+	}
+	return writeConfigFile(configPath, data)
+}
+
+// savePath returns the highest-priority config file that exists
+func savePath() (string, error) {
+	if user, err := UserConfigPath(); err == nil {
+		if _, err := os.Stat(user); err == nil {
+			return user, nil
+		}
+	}
+	return GetConfigPath()
+}
+
+// writeConfigFile replaces a file with config data readable only by the
+// current user. The data is written to a temporary file in the same folder
+// and renamed over the old one, so a crash or a full disk never leaves a
+// half-written config behind.
+func writeConfigFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
 
-	return os.WriteFile(configPath, data, 0644)
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// CreateTemp makes the file 0600, but a umask or inherited ACL may
+	// have loosened it
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// backupFile keeps the current contents of a config file as <path>.bak
+// before it is replaced
+func backupFile(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return writeConfigFile(path+".bak", data)
 }
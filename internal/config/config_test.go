@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// useUserConfig points the user config file at a temp dir and writes data
+// to it, returning its path
+func useUserConfig(t *testing.T, data string) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("APPDATA", dir)
+	path, err := UserConfigPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSaveBackupAndPermissions(t *testing.T) {
+	const original = `{"version": 1, "minio": {"endpoint": "minio.local:9000", "access_key": "ak", "secret_key": "sk", "bucket": "team"}}`
+	path := useUserConfig(t, original)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.MinIO.Bucket = "other"
+	// As if secret_ref had been resolved on load
+	cfg.MinIO.SecretRef = "os:team"
+	cfg.MinIO.SecretKey = "resolved-secret"
+
+	if err := cfg.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := os.ReadFile(path + ".bak")
+	if err != nil {
+		t.Fatalf("no backup: %v", err)
+	}
+	if string(backup) != original {
+		t.Errorf("backup = %s, want the previous file", backup)
+	}
+
+	saved, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(saved), `"other"`) {
+		t.Errorf("saved config does not have the new bucket:\n%s", saved)
+	}
+	if strings.Contains(string(saved), "resolved-secret") {
+		t.Errorf("secret key from secret_ref was written back:\n%s", saved)
+	}
+
+	if runtime.GOOS != "windows" {
+		for _, p := range []string{path, path + ".bak"} {
+			info, err := os.Stat(p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if perm := info.Mode().Perm(); perm != 0600 {
+				t.Errorf("%s has mode %o, want 600", filepath.Base(p), perm)
+			}
+		}
+	}
+}
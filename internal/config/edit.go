@@ -0,0 +1,150 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EditFile applies edit to the raw document of one config file and writes
+// it back, leaving values from other layers out of it. The file is only
+// written when the edit adds no validation problems; the previous version
+// is kept as <path>.bak.
+func EditFile(path string, edit func(doc map[string]any) error) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	data, err = upgradeFile(path, data)
+	if err != nil {
+		return err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := edit(doc); err != nil {
+		return err
+	}
+
+	doc["version"] = CurrentVersion
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := newProblems(path, out); err != nil {
+		return err
+	}
+	if err := backupFile(path); err != nil {
+		return err
+	}
+	return writeConfigFile(path, out)
+}
+
+// Check validates the effective config with every selection of sections:
+// the top level or default_profile, and each profile in turn. Each problem
+// is reported once. The remote config is checked as last fetched, so
+// validation never waits for the server.
+func Check() error {
+	return check(nil)
+}
+
+func check(pending map[string][]byte) error {
+	cfg, err := load(pending, false)
+	if err != nil {
+		return err
+	}
+
+	v := &validator{}
+	seen := make(map[string]bool)
+	for _, name := range append([]string{""}, cfg.ProfileNames()...) {
+		c := *cfg
+		// An unknown default_profile is reported by Validate
+		_ = c.UseProfile(name)
+
+		err := c.Validate()
+		var ve *ValidationError
+		if !errors.As(err, &ve) {
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		for _, fe := range ve.Errors {
+			if !seen[fe.Error()] {
+				seen[fe.Error()] = true
+				v.errs = append(v.errs, fe)
+			}
+		}
+	}
+	return v.err()
+}
+
+// newProblems returns the validation problems that writing data to path
+// would add. Problems the config already has are left out, so that a
+// broken config can be repaired one setting at a time.
+func newProblems(path string, data []byte) error {
+	after := check(map[string][]byte{path: data})
+	var ve *ValidationError
+	if !errors.As(after, &ve) {
+		return after
+	}
+
+	old := make(map[string]bool)
+	var before *ValidationError
+	if errors.As(check(nil), &before) {
+		for _, fe := range before.Errors {
+			old[fe.Error()] = true
+		}
+	}
+
+	v := &validator{}
+	for _, fe := range ve.Errors {
+		if !old[fe.Error()] {
+			v.errs = append(v.errs, fe)
+		}
+	}
+	return v.err()
+}
+
+// maskedSecret replaces secret values in output
+const maskedSecret = "********"
+
+// IsSecret reports whether the value at a config path is a secret that
+// must not be shown
+func IsSecret(path string) bool {
+	return path == "secret_key" || strings.HasSuffix(path, ".secret_key")
+}
+
+// KeyPath returns the path in the config files of a setting in effect.
+// With a profile active, minio and mount settings live in that profile,
+// e.g. "minio.bucket" is "profiles.work.minio.bucket".
+func (c *Config) KeyPath(key string) string {
+	if c.activeProfile != "" {
+		for _, section := range []string{"minio", "mount"} {
+			if key == section || strings.HasPrefix(key, section+".") {
+				return "profiles." + c.activeProfile + "." + key
+			}
+		}
+	}
+	return key
+}
+
+// SetKey stores a string value, parsed for the type of the setting, at a
+// config path in a raw document
+func SetKey(doc map[string]any, path, raw string) error {
+	return setRaw(doc, path, raw, Origin{}, map[string]Origin{})
+}
+
+// UnsetKey removes the value at a config path from a raw document,
+// reporting whether there was one
+func UnsetKey(doc map[string]any, path string) bool {
+	if _, ok := lookup(doc, path); !ok {
+		return false
+	}
+	deleteValue(doc, path)
+	return true
+}
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// variablePattern matches "$${" (a literal "${"), "${NAME}" and the
+// built-in variables such as "{username}" or "{username:lower}"
+var variablePattern = regexp.MustCompile(`\$\$\{|\$\{([^}]*)\}|\{(username|hostname|domain)(?::(lower|upper))?\}`)
+
+// envName is a valid environment variable name
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// builtins are the values of the built-in variables
+var builtins = map[string]func() (string, error){
+	"username": func() (string, error) {
+		u, err := user.Current()
+		if err != nil {
+			return "", err
+		}
+		// Windows returns DOMAIN\user
+		name := u.Username
+		if i := strings.LastIndex(name, `\`); i >= 0 {
+			name = name[i+1:]
+		}
+		return name, nil
+	},
+	"hostname": os.Hostname,
+	"domain": func() (string, error) {
+		if d := os.Getenv("USERDOMAIN"); d != "" {
+			return d, nil
+		}
+		if u, err := user.Current(); err == nil {
+			if d, _, ok := strings.Cut(u.Username, `\`); ok {
+				return d, nil
+			}
+		}
+		return "", fmt.Errorf("this machine has no logon domain")
+	},
+}
+
+// expandable are the settings whose values may contain variables, written
+// without the profile and list index: names and locations that differ per
+// user or machine. Secrets, commands and metadata are left as written so a
+// stray "${" in them is never rewritten.
+var expandable = map[string]bool{
+	"minio.endpoint":          true,
+	"minio.bucket":            true,
+	"mount.drive_letter":      true,
+	"queue.dir":               true,
+	"watch.rules.folder":      true,
+	"watch.rules.prefix":      true,
+	"watch.rules.sent_folder": true,
+}
+
+// settingIndex matches a list index in a config path
+var settingIndex = regexp.MustCompile(`\[\d+\]`)
+
+// isExpandable reports whether the value at a config path takes variables
+func isExpandable(path string) bool {
+	if rest, ok := strings.CutPrefix(path, "profiles."); ok {
+		_, path, _ = strings.Cut(rest, ".")
+	}
+	return expandable[settingIndex.ReplaceAllString(path, "")]
+}
+
+// expandDoc expands the variables in the expandable settings of a raw
+// config document, reporting each value that refers to an unknown variable
+func expandDoc(doc map[string]any) error {
+	v := &validator{}
+	expandValue(v, doc, "")
+	sort.Slice(v.errs, func(i, j int) bool { return v.errs[i].Path < v.errs[j].Path })
+	return v.err()
+}
+
+func expandValue(v *validator, value any, path string) any {
+	switch x := value.(type) {
+	case string:
+		if !isExpandable(path) {
+			return x
+		}
+		s, err := Expand(x)
+		if err != nil {
+			v.add(path, "%v", err)
+			return x
+		}
+		return s
+	case map[string]any:
+		for k, item := range x {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			x[k] = expandValue(v, item, p)
+		}
+	case []any:
+		for i, item := range x {
+			x[i] = expandValue(v, item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	}
+	return value
+}
+
+// Expand replaces ${NAME} with the environment variable NAME and
+// {username}, {hostname} and {domain} with their values, optionally
+// lowercased or uppercased as in {username:lower}. "$${" stands for a
+// literal "${".
+func Expand(s string) (string, error) {
+	if !strings.Contains(s, "{") {
+		return s, nil
+	}
+
+	var firstErr error
+	out := variablePattern.ReplaceAllStringFunc(s, func(m string) string {
+		if m == "$${" {
+			return "${"
+		}
+		sub := variablePattern.FindStringSubmatch(m)
+
+		if strings.HasPrefix(m, "${") {
+			name := sub[1]
+			if !envName.MatchString(name) {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("invalid variable %s; use ${NAME} with letters, digits and underscores", m)
+				}
+				return m
+			}
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("unresolved variable %s: environment variable %s is not set", m, name)
+				}
+				return m
+			}
+			return value
+		}
+
+		value, err := builtins[sub[2]]()
+		if err == nil && value == "" {
+			err = fmt.Errorf("it is empty")
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unresolved variable %s: %v", m, err)
+			}
+			return m
+		}
+		switch sub[3] {
+		case "lower":
+			value = strings.ToLower(value)
+		case "upper":
+			value = strings.ToUpper(value)
+		}
+		return value
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return out, nil
+}
@@ -0,0 +1,466 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override config values,
+// e.g. MINIODRIVE_MINIO_BUCKET for minio.bucket
+const EnvPrefix = "MINIODRIVE_"
+
+// ProfileEnv selects the profile when no -profile flag is given
+const ProfileEnv = EnvPrefix + "PROFILE"
+
+// Layers, from lowest to highest priority
+const (
+	LayerDefault = "default"
+	LayerMachine = "machine" // config.json next to the executable
+	LayerUser    = "user"    // config.json in the user config dir
+	LayerEnv     = "env"     // MINIODRIVE_* variables
+	LayerFlag    = "flag"    // -set key=value
+)
+
+// Origin tells where an effective config value came from
+type Origin struct {
+	Layer  string
+	Source string // file path, variable name or flag; empty for defaults
+}
+
+func (o Origin) String() string {
+	if o.Source == "" {
+		return o.Layer
+	}
+	return o.Layer + " (" + o.Source + ")"
+}
+
+// setFlags collects -set key=value flags
+type setFlags []string
+
+func (s *setFlags) String() string { return strings.Join(*s, " ") }
+
+func (s *setFlags) Set(v string) error {
+	if !strings.Contains(v, "=") {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	*s = append(*s, v)
+	return nil
+}
+
+// overrides holds the -set flags of this process, applied as the last layer
+var overrides setFlags
+
+// AddFlags registers -profile and -set on a flag set and returns the
+// profile name
+func AddFlags(fs *flag.FlagSet) *string {
+	fs.Var(&overrides, "set", "override a config value, e.g. -set upload.retries=5 (repeatable)")
+	return fs.String("profile", "", "config profile to use")
+}
+
+// UserConfigPath returns the per-user config.json, which overrides the
+// machine-wide file next to the executable
+func UserConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "MinIODrive", "config.json"), nil
+}
+
+// Files returns the config file layers in priority order, whether or not
+// they exist. The user file is left out when the user config dir is
+// unknown.
+func Files() ([]Origin, error) {
+	machine, err := GetConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	files := []Origin{{Layer: LayerMachine, Source: machine}}
+	if user, err := UserConfigPath(); err == nil {
+		files = append(files, Origin{Layer: LayerUser, Source: user})
+	}
+	return files, nil
+}
+
+// layer is one parsed config document and where it came from
+type layer struct {
+	origin Origin
+	doc    map[string]any
+}
+
+// loadLayers merges the config files, the remote config, environment
+// variables and -set flags into one document, enforces the administrator
+// policy over them and records the origin of every value in it
+func loadLayers(pending map[string][]byte, refresh bool) (map[string]any, map[string]Origin, map[string]Lock, *RemoteStatus, error) {
+	files, err := Files()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	admin, err := loadAdminPolicy()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	var layers []layer
+	machine := map[string]any{}
+
+	for _, f := range files {
+		data, ok := pending[f.Source]
+		if !ok {
+			var err error
+			if data, err = os.ReadFile(f.Source); errors.Is(err, os.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, nil, nil, nil, err
+			}
+		}
+
+		data, err := upgradeFile(f.Source, data)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("%s: %w", f.Source, err)
+		}
+		var doc map[string]any
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("%s: %w", f.Source, err)
+		}
+		delete(doc, "version")
+		if f.Layer == LayerMachine {
+			machine = doc
+		}
+		layers = append(layers, layer{f, doc})
+	}
+	if len(layers) == 0 {
+		paths := make([]string, len(files))
+		for i, f := range files {
+			paths[i] = f.Source
+		}
+		return nil, nil, nil, nil, fmt.Errorf("no config file found; create one of: %s", strings.Join(paths, ", "))
+	}
+
+	doc, origins, locks, err := mergeLayers(layers, machine, admin)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// The remote section may come from any layer, so the remote config is
+	// fetched once the others are merged and then slotted in right above
+	// the machine-wide file
+	remote, err := remoteSettings(doc)
+	if err != nil || remote.URL == "" {
+		return doc, origins, locks, nil, err
+	}
+	remoteDoc, status := remoteLayer(remote, refresh)
+	if remoteDoc == nil {
+		return doc, origins, locks, status, nil
+	}
+	at := 0
+	if layers[0].origin.Layer == LayerMachine {
+		at = 1
+	}
+	layers = append(layers[:at], append([]layer{{Origin{Layer: LayerRemote, Source: remote.URL}, remoteDoc}}, layers[at:]...)...)
+
+	doc, origins, locks, err = mergeLayers(layers, machine, admin)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return doc, origins, locks, status, nil
+}
+
+// mergeLayers merges the given documents in order, then the environment
+// variables and -set flags, and enforces the administrator policy.
+// Overrides of minio and mount settings reach every profile as well, so
+// they hold whichever profile is selected later.
+func mergeLayers(layers []layer, machine map[string]any, admin *adminPolicy) (map[string]any, map[string]Origin, map[string]Lock, error) {
+	doc := map[string]any{}
+	origins := map[string]Origin{}
+	for _, l := range layers {
+		merge(doc, l.doc, "", l.origin, origins)
+	}
+
+	for _, path := range leafPaths(reflect.TypeOf(Config{}), "") {
+		name := EnvName(path)
+		raw, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		for _, p := range profilePaths(doc, path) {
+			if err := setRaw(doc, p, raw, Origin{Layer: LayerEnv, Source: name}, origins); err != nil {
+				return nil, nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+
+	for _, kv := range overrides {
+		path, raw, _ := strings.Cut(kv, "=")
+		path = strings.TrimSpace(path)
+		if admin != nil {
+			if key, ok := admin.covering(path); ok {
+				return nil, nil, nil, &LockedError{Lock: Lock{Key: path, Reason: admin.reason(admin.Locked[key])}}
+			}
+		}
+		// Only the key is recorded, as the value may be a secret
+		for _, p := range profilePaths(doc, path) {
+			if err := setRaw(doc, p, raw, Origin{Layer: LayerFlag, Source: "-set " + path}, origins); err != nil {
+				return nil, nil, nil, fmt.Errorf("-set %s: %w", path, err)
+			}
+		}
+	}
+
+	var locks map[string]Lock
+	if admin != nil {
+		locks = admin.enforce(doc, machine, origins)
+	}
+	return doc, origins, locks, nil
+}
+
+// EnvName returns the environment variable overriding a config path
+func EnvName(path string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// merge copies src into dst. Objects are merged key by key; arrays and
+// plain values replace what was there.
+func merge(dst, src map[string]any, prefix string, origin Origin, origins map[string]Origin) {
+	for k, v := range src {
+		path := prefix + k
+		if obj, ok := v.(map[string]any); ok {
+			sub, ok := dst[k].(map[string]any)
+			if !ok {
+				sub = map[string]any{}
+				dst[k] = sub
+			}
+			merge(sub, obj, path+".", origin, origins)
+			continue
+		}
+		dst[k] = v
+		origins[path] = origin
+	}
+}
+
+// setRaw parses a string for the config path and stores it in doc
+func setRaw(doc map[string]any, path, raw string, origin Origin, origins map[string]Origin) error {
+	t, err := KeyType(path)
+	if err != nil {
+		return err
+	}
+	v, err := parseValue(t, raw)
+	if err != nil {
+		return err
+	}
+
+	setValue(doc, path, v)
+
+	// The new value replaces whatever lower layers set below this path
+	for p := range origins {
+		if strings.HasPrefix(p, path+".") {
+			delete(origins, p)
+		}
+	}
+	origins[path] = origin
+	return nil
+}
+
+// KeyType returns the Go type of the value at a dotted config path such
+// as "mount.port", "upload.metadata.dept" or "profiles.work.minio.bucket"
+func KeyType(path string) (reflect.Type, error) {
+	if path == "" {
+		return nil, errors.New("empty config key")
+	}
+	t := reflect.TypeOf(Config{})
+	for _, k := range strings.Split(path, ".") {
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := fieldByTag(t, k)
+			if !ok {
+				return nil, fmt.Errorf("unknown config key %q", path)
+			}
+			t = f.Type
+		case reflect.Map:
+			if k == "" {
+				return nil, fmt.Errorf("empty name in config key %q", path)
+			}
+			t = t.Elem()
+		default:
+			return nil, fmt.Errorf("unknown config key %q", path)
+		}
+	}
+	return t, nil
+}
+
+// fieldByTag finds an exported struct field by its JSON name
+func fieldByTag(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		if jsonName(f) == name {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// leafPaths lists the paths of the values in a struct that are not
+// themselves structs, leaving out profiles, whose names are not known
+func leafPaths(t reflect.Type, prefix string) []string {
+	var paths []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" || jsonName(f) == "-" || jsonName(f) == "version" {
+			continue
+		}
+		path := prefix + jsonName(f)
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			paths = append(paths, leafPaths(f.Type, path+".")...)
+		case f.Type.Kind() == reflect.Map && f.Type.Elem().Kind() == reflect.Struct:
+			// profiles
+		default:
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// parseValue converts a string from the environment or a flag to the JSON
+// value for a config type. Lists may be written as "a,b" and string maps
+// as "k=v,k2=v2"; anything else can be given as JSON.
+func parseValue(t reflect.Type, raw string) (any, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return raw, nil
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("expected true or false, got %q", raw)
+		}
+		return b, nil
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a whole number, got %q", raw)
+		}
+		return n, nil
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a non-negative whole number, got %q", raw)
+		}
+		return n, nil
+	}
+
+	trimmed := strings.TrimSpace(raw)
+	if strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		return v, nil
+	}
+
+	switch {
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String:
+		list := []any{}
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		return list, nil
+	case t.Kind() == reflect.Map && t.Elem().Kind() == reflect.String:
+		m := map[string]any{}
+		for _, pair := range strings.Split(raw, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			k, v, ok := strings.Cut(pair, "=")
+			if !ok {
+				return nil, fmt.Errorf("expected key=value pairs, got %q", pair)
+			}
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("give this value as JSON")
+}
+
+// Setting is one effective config value
+type Setting struct {
+	Key    string
+	Value  string // JSON encoded
+	Origin Origin
+	Locked string // why the administrator policy pins it, empty when not locked
+}
+
+// Settings lists every effective value with its origin, sorted by key.
+// Secret keys are masked.
+func (c *Config) Settings() ([]Setting, error) {
+	effective := *c
+	effective.Version = CurrentVersion
+	data, err := json.Marshal(&effective)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	var out []Setting
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			path := prefix + k
+			if sub, ok := v.(map[string]any); ok && len(sub) > 0 {
+				walk(path+".", sub)
+				continue
+			}
+			if IsSecret(path) && v != "" {
+				v = maskedSecret
+			}
+			value, _ := json.Marshal(v)
+			setting := Setting{Key: path, Value: string(value), Origin: c.Origin(path)}
+			if l, ok := c.Locked(path); ok {
+				setting.Locked = l.Reason
+			}
+			out = append(out, setting)
+		}
+	}
+	walk("", doc)
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// Origin returns where the value at a config path came from. Values no
+// layer set, such as normalized defaults, come from the defaults.
+func (c *Config) Origin(path string) Origin {
+	for p := c.KeyPath(path); p != ""; {
+		if o, ok := c.origins[p]; ok {
+			return o
+		}
+		i := strings.LastIndex(p, ".")
+		if i < 0 {
+			break
+		}
+		p = p[:i]
+	}
+	return Origin{Layer: LayerDefault}
+}
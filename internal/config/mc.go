@@ -0,0 +1,141 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// MCAlias is a host alias in the MinIO Client (mc) config.json
+type MCAlias struct {
+	URL       string `json:"url"`
+	AccessKey string `json:"accessKey"`
+	SecretKey string `json:"secretKey"`
+	API       string `json:"api"`
+	Path      string `json:"path"`
+}
+
+// MCConfigPath returns the config.json mc uses by default
+func MCConfigPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(home, "mc", "config.json"), nil
+	}
+	return filepath.Join(home, ".mc", "config.json"), nil
+}
+
+// readMCFile returns an mc config.json as raw fields and its aliases.
+// Version 9 files keep the aliases under "hosts".
+func readMCFile(path string) (map[string]json.RawMessage, string, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, "", nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	field := "aliases"
+	if _, ok := doc[field]; !ok {
+		if _, ok := doc["hosts"]; ok {
+			field = "hosts"
+		}
+	}
+	aliases := map[string]json.RawMessage{}
+	if raw, ok := doc[field]; ok {
+		if err := json.Unmarshal(raw, &aliases); err != nil {
+			return nil, "", nil, fmt.Errorf("%s: %s: %w", path, field, err)
+		}
+	}
+	return doc, field, aliases, nil
+}
+
+// ReadMCAlias reads an alias from an mc config.json into a MinIO section.
+// mc aliases carry no bucket, so Bucket is left empty.
+func ReadMCAlias(path, name string) (*MinIOConfig, error) {
+	_, _, aliases, err := readMCFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := aliases[name]
+	if !ok {
+		names := make([]string, 0, len(aliases))
+		for n := range aliases {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("alias %q not found in %s; aliases: %s", name, path, strings.Join(names, ", "))
+	}
+	var a MCAlias
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return nil, fmt.Errorf("%s: alias %s: %w", path, name, err)
+	}
+	if a.URL == "" {
+		return nil, fmt.Errorf("alias %q has no url", name)
+	}
+	if u, err := url.Parse(a.URL); err != nil || u.Host == "" {
+		return nil, fmt.Errorf("alias %q has an invalid url %q", name, a.URL)
+	} else if strings.Trim(u.Path, "/") != "" {
+		return nil, fmt.Errorf("alias %q points at a path (%s); only whole servers can be imported", name, a.URL)
+	}
+
+	m := &MinIOConfig{Endpoint: a.URL, AccessKey: a.AccessKey, SecretKey: a.SecretKey}
+	m.normalize()
+	return m, nil
+}
+
+// MCAlias returns the mc alias for a MinIO section with resolved keys
+func (m *MinIOConfig) MCAlias() MCAlias {
+	scheme := "http"
+	if m.UseSSL {
+		scheme = "https"
+	}
+	return MCAlias{
+		URL:       scheme + "://" + m.Endpoint,
+		AccessKey: m.AccessKey,
+		SecretKey: m.SecretKey,
+		API:       "S3v4",
+		Path:      "auto",
+	}
+}
+
+// WriteMCAlias adds or replaces an alias in an mc config.json, keeping the
+// other aliases. The file is created when missing.
+func WriteMCAlias(path, name string, alias MCAlias) error {
+	doc, field, aliases, err := readMCFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		doc, field, aliases, err = map[string]json.RawMessage{"version": json.RawMessage(`"10"`)}, "aliases", map[string]json.RawMessage{}, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	raw, err := json.Marshal(alias)
+	if err != nil {
+		return err
+	}
+	aliases[name] = raw
+	if doc[field], err = json.Marshal(aliases); err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(doc, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeConfigFile(path, append(out, '\n'))
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// CurrentVersion is the config format written by this build. Files
+// without a version field are version 0.
+const CurrentVersion = 1
+
+// migration upgrades a raw config document from one version to the next
+type migration struct {
+	from        int
+	description string
+	apply       func(doc map[string]any) error
+}
+
+// migrations must be in order, one step per version
+var migrations = []migration{
+	{0, "add the upload section and canonical endpoints and drive letters", migrateV0},
+}
+
+// migrateV0 upgrades files written before versioning. The uploader read
+// upload.show_notification before the section existed, so it is added with
+// notifications on. Endpoints with a scheme become host:port plus use_ssl,
+// and "z:" drive letters become "Z", in every minio and mount section.
+func migrateV0(doc map[string]any) error {
+	if _, ok := doc["upload"]; !ok {
+		doc["upload"] = map[string]any{"show_notification": true}
+	}
+
+	sections := []map[string]any{doc}
+	if profiles, ok := doc["profiles"].(map[string]any); ok {
+		for _, p := range profiles {
+			if p, ok := p.(map[string]any); ok {
+				sections = append(sections, p)
+			}
+		}
+	}
+
+	for _, s := range sections {
+		if m, ok := s["minio"].(map[string]any); ok {
+			if endpoint, ok := m["endpoint"].(string); ok {
+				endpoint, ssl := v0Endpoint(endpoint)
+				m["endpoint"] = endpoint
+				if ssl {
+					m["use_ssl"] = true
+				}
+			}
+		}
+		if m, ok := s["mount"].(map[string]any); ok {
+			if letter, ok := m["drive_letter"].(string); ok && letter != "" {
+				letter = strings.ToUpper(strings.TrimSpace(letter))
+				m["drive_letter"] = strings.TrimSuffix(strings.TrimSuffix(letter, `\`), ":")
+			}
+		}
+	}
+	return nil
+}
+
+// v0Endpoint splits a version 0 endpoint into host:port and whether it
+// used https. It is kept apart from MinIOConfig.normalize so that later
+// changes to the live rules do not change what this migration does.
+func v0Endpoint(endpoint string) (string, bool) {
+	endpoint = strings.TrimSpace(endpoint)
+	lower := strings.ToLower(endpoint)
+	ssl := false
+	switch {
+	case strings.HasPrefix(lower, "https://"):
+		endpoint = endpoint[len("https://"):]
+		ssl = true
+	case strings.HasPrefix(lower, "http://"):
+		endpoint = endpoint[len("http://"):]
+	}
+	return strings.TrimRight(endpoint, "/"), ssl
+}
+
+// upgrade runs the migrations a config document needs. It returns the
+// version the document had and the upgraded data, which is the input
+// unchanged when it is already current.
+func upgrade(data []byte) (int, []byte, error) {
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return 0, nil, err
+	}
+
+	version := 0
+	if v, ok := doc["version"]; ok {
+		f, ok := v.(float64)
+		if !ok || f != float64(int(f)) || f < 0 {
+			return 0, nil, &FieldError{Path: "version", Msg: fmt.Sprintf("must be a whole number, got %v", v)}
+		}
+		version = int(f)
+	}
+	if version > CurrentVersion {
+		return 0, nil, &FieldError{Path: "version", Msg: fmt.Sprintf(
+			"config format %d is newer than this program supports (%d); update MinIO Drive", version, CurrentVersion)}
+	}
+	if version == CurrentVersion {
+		return version, data, nil
+	}
+
+	for _, m := range migrations {
+		if m.from < version {
+			continue
+		}
+		if err := m.apply(doc); err != nil {
+			return 0, nil, fmt.Errorf("failed to migrate config from version %d (%s): %w", m.from, m.description, err)
+		}
+	}
+	doc["version"] = CurrentVersion
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return 0, nil, err
+	}
+	return version, out, nil
+}
+
+// upgradeFile brings the config file at path to CurrentVersion and returns
+// its contents. The original is kept as <path>.v<N>.<time>.bak, a new file
+// for every upgrade so an older backup is never mistaken for this one.
+// When the backup or the file cannot be written, for example under Program
+// Files, the upgrade is only applied in memory and tried again on the next
+// start.
+func upgradeFile(path string, data []byte) ([]byte, error) {
+	from, out, err := upgrade(data)
+	if err != nil || from == CurrentVersion {
+		return out, err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.%s.bak", path, from, time.Now().Format("20060102-150405"))
+	if err := writeNewFile(backup, data); err != nil {
+		return out, nil
+	}
+	if err := writeConfigFile(path, out); err != nil {
+		os.Remove(backup) // the original is still in place
+	}
+	return out, nil
+}
+
+// writeNewFile writes data to a file that must not exist yet, readable
+// only by the current user
+func writeNewFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
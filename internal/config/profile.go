@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Profile is a named server, bucket and mount combination
+type Profile struct {
+	MinIO MinIOConfig `json:"minio"`
+	Mount MountConfig `json:"mount"`
+}
+
+// ProfileNames returns the configured profile names in sorted order
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ActiveProfile returns the name of the profile in use, or "" when the
+// top-level minio and mount sections are used
+func (c *Config) ActiveProfile() string {
+	return c.activeProfile
+}
+
+// UseProfile makes the named profile's minio and mount settings the active
+// ones. An empty name selects default_profile, and if that is also empty the
+// top-level minio and mount sections stay in effect.
+func (c *Config) UseProfile(name string) error {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+
+	// Remember the top-level sections so Save can write them back unchanged
+	if c.base == nil {
+		c.base = &Profile{MinIO: c.MinIO, Mount: c.Mount}
+	}
+
+	if name == "" {
+		c.MinIO = c.base.MinIO
+		c.Mount = c.base.Mount
+		c.activeProfile = ""
+		return nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	c.MinIO = profile.MinIO
+	c.Mount = profile.Mount
+	c.activeProfile = name
+	return nil
+}
+
+// LoadProfile reads the config layers and selects the named profile, or
+// the one in MINIODRIVE_PROFILE when name is empty
+func LoadProfile(name string) (*Config, error) {
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+
+	if err := cfg.UseProfile(name); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.MinIO.ResolveSecret(); err != nil {
+		return nil, &FieldError{Path: cfg.sectionPath("minio") + ".secret_ref", Msg: err.Error()}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
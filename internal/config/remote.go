@@ -0,0 +1,436 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LayerRemote is the centrally managed config fetched from remote.url. It
+// overrides the machine-wide file and is overridden by the user's.
+const LayerRemote = "remote"
+
+// DefaultRemoteRefresh is how often the remote config is fetched when
+// remote.refresh_minutes is not set
+const DefaultRemoteRefresh = 60 * time.Minute
+
+// remoteTimeout bounds a fetch made while loading the config, so an
+// unreachable server holds up a program start only briefly
+const remoteTimeout = 10 * time.Second
+
+// remoteRetry is how long to wait after a failed fetch before trying again,
+// unless refresh_minutes is shorter. Offline, only the first load in that
+// time waits for the server.
+const remoteRetry = 5 * time.Minute
+
+// maxRemoteSize limits the remote config and signature downloads
+const maxRemoteSize = 1 << 20
+
+// RemoteStatus tells which copy of the remote config is in effect
+type RemoteStatus struct {
+	URL       string
+	FetchedAt time.Time // when the copy in effect was fetched, zero when there is none
+	Err       error     // why the last fetch failed, nil when the copy is current
+}
+
+// remoteCache is the last good copy of the remote config and the outcome
+// of the last fetch. The signature is kept with the copy and checked again
+// on every load.
+type remoteCache struct {
+	URL       string    `json:"url"`
+	ETag      string    `json:"etag,omitempty"`
+	FetchedAt time.Time `json:"fetched_at,omitempty"` // zero until a good copy was fetched
+	Config    string    `json:"config,omitempty"`
+	Signature string    `json:"signature,omitempty"`
+
+	CheckedAt time.Time `json:"checked_at"`           // last fetch, successful or not
+	LastError string    `json:"last_error,omitempty"` // why the last fetch failed
+
+	serial int64 // from the verified signature
+}
+
+// remoteSignature is the .sig file served next to the remote config. Along
+// with the config it signs the URL, a serial that grows with every release
+// and an expiry, so a signed copy cannot be served from another URL,
+// rolled back to an older release or replayed forever.
+type remoteSignature struct {
+	URL       string    `json:"url"`
+	Serial    int64     `json:"serial"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Signature string    `json:"signature"` // base64 Ed25519 signature of payload
+}
+
+// payload returns the bytes the signature covers
+func (s *remoteSignature) payload(data []byte) []byte {
+	sum := sha256.Sum256(data)
+	return fmt.Appendf(nil, "minio-drive remote config\n%s\n%d\n%s\n%x\n",
+		s.URL, s.Serial, s.ExpiresAt.UTC().Format(time.RFC3339), sum)
+}
+
+// SignRemote signs a config to be published at rawURL and returns the .sig
+// file to serve next to it. serial must be higher than that of every
+// earlier release, or clients that have one will refuse it.
+func SignRemote(key ed25519.PrivateKey, rawURL string, serial int64, expires time.Time, data []byte) ([]byte, error) {
+	s := remoteSignature{URL: rawURL, Serial: serial, ExpiresAt: expires.UTC().Truncate(time.Second)}
+	s.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, s.payload(data)))
+	out, err := json.MarshalIndent(&s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// due reports whether the server should be asked again. After a failure
+// that is sooner than after a success.
+func (c *remoteCache) due(r *RemoteConfig) bool {
+	wait := r.refresh()
+	if c.LastError != "" && remoteRetry < wait {
+		wait = remoteRetry
+	}
+	return time.Since(c.CheckedAt) >= wait
+}
+
+// userCacheDir returns the folder the remote config cache is kept in;
+// tests point it elsewhere
+var userCacheDir = os.UserCacheDir
+
+// RemoteCachePath returns where the last good remote config is kept
+func RemoteCachePath() (string, error) {
+	dir, err := userCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "MinIODrive", "remote-config.json"), nil
+}
+
+// refresh returns how long a fetched copy stays current
+func (r *RemoteConfig) refresh() time.Duration {
+	if r.RefreshMinutes > 0 {
+		return time.Duration(r.RefreshMinutes) * time.Minute
+	}
+	return DefaultRemoteRefresh
+}
+
+// publicKey decodes the pinned Ed25519 key
+func (r *RemoteConfig) publicKey() (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(r.PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("remote.public_key must be a base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// signatureURL returns where the detached signature of the config is
+// served: the config URL with ".sig" appended to its path
+func (r *RemoteConfig) signatureURL() (string, error) {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		return "", err
+	}
+	u.Path += ".sig"
+	u.RawPath = ""
+	return u.String(), nil
+}
+
+// checkURL refuses URLs the config may not be fetched from. Plain http is
+// allowed, such as for a local stand-in: the signature made with the pinned
+// key protects the content either way, and the URL it covers keeps a copy
+// from being served from somewhere else.
+func (r *RemoteConfig) checkURL() error {
+	u, err := url.Parse(r.URL)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return fmt.Errorf("remote.url must be an http:// or https:// URL, got %q", r.URL)
+	}
+	return nil
+}
+
+// verify checks the signature file of data against the pinned key and
+// the config URL
+func (r *RemoteConfig) verify(data []byte, signature string) (*remoteSignature, error) {
+	key, err := r.publicKey()
+	if err != nil {
+		return nil, err
+	}
+	var s remoteSignature
+	if err := json.Unmarshal([]byte(signature), &s); err != nil {
+		return nil, fmt.Errorf("signature of %s was not written by config sign", r.URL)
+	}
+	sig, err := base64.StdEncoding.DecodeString(s.Signature)
+	if err != nil || !ed25519.Verify(key, s.payload(data), sig) {
+		return nil, fmt.Errorf("signature of %s does not match remote.public_key", r.URL)
+	}
+	if s.URL != r.URL {
+		return nil, fmt.Errorf("%s is signed for %s", r.URL, s.URL)
+	}
+	return &s, nil
+}
+
+// parseRemote turns a verified remote config into a layer document. It may
+// not point elsewhere itself, so its remote section is dropped.
+func parseRemote(data []byte) (map[string]any, error) {
+	_, data, err := upgrade(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &Config{}); err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	delete(doc, "version")
+	delete(doc, "remote")
+	return doc, nil
+}
+
+// readRemoteCache returns the cache for r, or nil when there is none for
+// its URL. Config is empty when no fetch has succeeded yet. A copy whose
+// signature no longer verifies, for example after the key was changed, is
+// an error.
+func readRemoteCache(r *RemoteConfig) (*remoteCache, error) {
+	path, err := RemoteCachePath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var c remoteCache
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.URL != r.URL {
+		return nil, nil
+	}
+	if c.Config == "" {
+		return &c, nil
+	}
+	signed, err := r.verify([]byte(c.Config), c.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("cached copy: %w", err)
+	}
+	c.serial = signed.Serial
+	return &c, nil
+}
+
+func writeRemoteCache(c *remoteCache) error {
+	path, err := RemoteCachePath()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeConfigFile(path, data)
+}
+
+// get downloads a URL, sending If-None-Match when etag is set. A nil body
+// means the server answered 304 Not Modified.
+func get(ctx context.Context, rawURL, etag string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && etag != "":
+		return nil, etag, nil
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", fmt.Errorf("GET %s: %s", rawURL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(body) > maxRemoteSize {
+		return nil, "", fmt.Errorf("GET %s: larger than %d bytes", rawURL, maxRemoteSize)
+	}
+	return body, resp.Header.Get("ETag"), nil
+}
+
+// FetchRemote downloads the remote config and its signature and, when the
+// signature verifies and the config is readable, stores it as the last good
+// copy. It reports whether the config differs from the previous copy. A
+// failure is recorded too, so loads do not keep waiting for the server.
+func FetchRemote(ctx context.Context, r RemoteConfig) (bool, error) {
+	if r.URL == "" {
+		return false, nil
+	}
+	cached, err := readRemoteCache(&r)
+	if err != nil || cached == nil {
+		// A copy that no longer verifies is replaced, whatever happens
+		cached = &remoteCache{URL: r.URL}
+	}
+
+	changed, err := fetchRemote(ctx, &r, cached)
+	cached.CheckedAt = time.Now()
+	cached.LastError = ""
+	if err != nil {
+		cached.LastError = err.Error()
+	}
+	if werr := writeRemoteCache(cached); err == nil {
+		err = werr
+	}
+	return changed, err
+}
+
+// fetchRemote downloads the remote config into c when it changed
+func fetchRemote(ctx context.Context, r *RemoteConfig, c *remoteCache) (bool, error) {
+	if err := r.checkURL(); err != nil {
+		return false, err
+	}
+	sigURL, err := r.signatureURL()
+	if err != nil {
+		return false, err
+	}
+
+	data, etag, err := get(ctx, r.URL, c.ETag)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		c.FetchedAt = time.Now()
+		return false, nil
+	}
+
+	sig, _, err := get(ctx, sigURL, "")
+	if err != nil {
+		return false, err
+	}
+	signed, err := r.verify(data, string(sig))
+	if err != nil {
+		return false, err
+	}
+	if time.Now().After(signed.ExpiresAt) {
+		return false, fmt.Errorf("signature of %s expired on %s", r.URL, signed.ExpiresAt.Local().Format(time.DateTime))
+	}
+	if c.Config != "" && (signed.Serial < c.serial || signed.Serial == c.serial && c.Config != string(data)) {
+		return false, fmt.Errorf("%s has serial %d, not newer than the %d already in use", r.URL, signed.Serial, c.serial)
+	}
+	if _, err := parseRemote(data); err != nil {
+		return false, fmt.Errorf("%s: %w", r.URL, err)
+	}
+
+	changed := c.Config != string(data)
+	c.ETag = etag
+	c.FetchedAt = time.Now()
+	c.Config = string(data)
+	c.Signature = string(sig)
+	c.serial = signed.Serial
+	return changed, nil
+}
+
+// RefreshRemote fetches the remote config when it is due, reporting whether
+// it changed. Until the retry interval has passed, a failed fetch is
+// reported again instead of being retried.
+func RefreshRemote(ctx context.Context, r RemoteConfig) (bool, error) {
+	if r.URL == "" {
+		return false, nil
+	}
+	cached, err := readRemoteCache(&r)
+	if err == nil && cached != nil && !cached.due(&r) {
+		if cached.LastError != "" {
+			return false, errors.New(cached.LastError)
+		}
+		return false, nil
+	}
+	return FetchRemote(ctx, r)
+}
+
+// remoteLayer returns the remote config document to merge, refreshing it
+// first when it is due and refresh is set. When the server cannot be
+// reached the last good copy is used; without one there is no remote layer
+// and the status tells why.
+func remoteLayer(r RemoteConfig, refresh bool) (map[string]any, *RemoteStatus) {
+	status := &RemoteStatus{URL: r.URL}
+
+	if refresh {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteTimeout)
+		defer cancel()
+		_, status.Err = RefreshRemote(ctx, r)
+	}
+
+	cached, err := readRemoteCache(&r)
+	if err != nil || cached == nil || cached.Config == "" {
+		if status.Err == nil {
+			status.Err = err
+		}
+		return nil, status
+	}
+	doc, err := parseRemote([]byte(cached.Config))
+	if err != nil {
+		status.Err = err
+		return nil, status
+	}
+	status.FetchedAt = cached.FetchedAt
+	return doc, status
+}
+
+// remoteSettings reads the remote section from a merged document
+func remoteSettings(doc map[string]any) (RemoteConfig, error) {
+	var r RemoteConfig
+	section, ok := doc["remote"]
+	if !ok {
+		return r, nil
+	}
+	data, err := json.Marshal(section)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("remote: %w", err)
+	}
+	r.URL = strings.TrimSpace(r.URL)
+	r.PublicKey = strings.TrimSpace(r.PublicKey)
+	return r, nil
+}
+
+func (r *RemoteConfig) validate(v *validator, path string) {
+	nonNegative(v, path+".refresh_minutes", r.RefreshMinutes)
+	if r.URL == "" {
+		return
+	}
+	if err := r.checkURL(); err != nil {
+		v.add(path+".url", "must be an http:// or https:// URL, got %q", r.URL)
+	}
+	if r.PublicKey == "" {
+		v.add(path+".public_key", "is required with remote.url")
+	} else if _, err := r.publicKey(); err != nil {
+		v.add(path+".public_key", "must be a base64 Ed25519 public key (32 bytes)")
+	}
+}
+
+// RemoteStatus returns the state of the remote config layer, or nil when
+// no remote.url is set
+func (c *Config) RemoteStatus() *RemoteStatus {
+	return c.remote
+}
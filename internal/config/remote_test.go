@@ -0,0 +1,239 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// remoteServer serves a remote config and its signature
+type remoteServer struct {
+	*httptest.Server
+	key ed25519.PrivateKey
+
+	mu     sync.Mutex
+	config []byte
+	sig    []byte
+	etag   string
+
+	configHits atomic.Int32
+	sigHits    atomic.Int32
+}
+
+// newRemoteServer starts a server and points the config cache and HTTP
+// client at it for the length of the test
+func newRemoteServer(t *testing.T) *remoteServer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &remoteServer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/config.json", func(w http.ResponseWriter, r *http.Request) {
+		s.configHits.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.etag != "" {
+			if r.Header.Get("If-None-Match") == s.etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", s.etag)
+		}
+		_, _ = w.Write(s.config)
+	})
+	mux.HandleFunc("/config.json.sig", func(w http.ResponseWriter, r *http.Request) {
+		s.sigHits.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		_, _ = w.Write(s.sig)
+	})
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+
+	cacheDir := t.TempDir()
+	userCacheDir = func() (string, error) { return cacheDir, nil }
+	t.Cleanup(func() { userCacheDir = os.UserCacheDir })
+	return s
+}
+
+// remote returns the settings pointing at the server and pinning its key
+func (s *remoteServer) remote() RemoteConfig {
+	public := s.key.Public().(ed25519.PublicKey)
+	return RemoteConfig{
+		URL:       s.URL + "/config.json",
+		PublicKey: base64.StdEncoding.EncodeToString(public),
+	}
+}
+
+// publish serves a config setting upload.retries, signed with key
+func (s *remoteServer) publish(t *testing.T, key ed25519.PrivateKey, retries int, serial int64) {
+	t.Helper()
+	data := []byte(fmt.Sprintf(`{"version": %d, "upload": {"retries": %d}}`, CurrentVersion, retries))
+	sig, err := SignRemote(key, s.URL+"/config.json", serial, time.Now().Add(time.Hour), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config, s.sig = data, sig
+}
+
+func retriesIn(doc map[string]any) any {
+	upload, _ := doc["upload"].(map[string]any)
+	return upload["retries"]
+}
+
+func TestFetchRemoteGoodSignature(t *testing.T) {
+	s := newRemoteServer(t)
+	s.publish(t, s.key, 7, 1)
+
+	changed, err := FetchRemote(context.Background(), s.remote())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("first fetch reported no change")
+	}
+
+	doc, status := remoteLayer(s.remote(), true)
+	if status.Err != nil {
+		t.Fatalf("status error: %v", status.Err)
+	}
+	if got := retriesIn(doc); got != float64(7) {
+		t.Errorf("upload.retries = %v, want 7", got)
+	}
+	if status.FetchedAt.IsZero() {
+		t.Error("FetchedAt not set")
+	}
+}
+
+func TestFetchRemoteBadSignature(t *testing.T) {
+	s := newRemoteServer(t)
+	_, other, _ := ed25519.GenerateKey(nil)
+	s.publish(t, other, 7, 1)
+
+	if _, err := FetchRemote(context.Background(), s.remote()); err == nil {
+		t.Fatal("config signed with another key was accepted")
+	}
+
+	doc, status := remoteLayer(s.remote(), true)
+	if doc != nil {
+		t.Errorf("remote layer = %v, want none", doc)
+	}
+	if status.Err == nil {
+		t.Error("status does not report the failed fetch")
+	}
+}
+
+func TestFetchRemoteNotModified(t *testing.T) {
+	s := newRemoteServer(t)
+	s.publish(t, s.key, 7, 1)
+	s.etag = `"v1"`
+
+	if _, err := FetchRemote(context.Background(), s.remote()); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := FetchRemote(context.Background(), s.remote())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("304 reported as a change")
+	}
+	if got := s.configHits.Load(); got != 2 {
+		t.Errorf("config requested %d times, want 2", got)
+	}
+	if got := s.sigHits.Load(); got != 1 {
+		t.Errorf("signature fetched %d times, want 1", got)
+	}
+
+	doc, _ := remoteLayer(s.remote(), true)
+	if got := retriesIn(doc); got != float64(7) {
+		t.Errorf("upload.retries = %v after 304, want 7", got)
+	}
+}
+
+func TestRemoteLayerOfflineUsesCache(t *testing.T) {
+	s := newRemoteServer(t)
+	s.publish(t, s.key, 7, 1)
+	r := s.remote()
+
+	if _, err := FetchRemote(context.Background(), r); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	// The copy is due, so the load tries the server once and records why
+	// it failed
+	cached, _ := readRemoteCache(&r)
+	cached.CheckedAt = time.Time{}
+	if err := writeRemoteCache(cached); err != nil {
+		t.Fatal(err)
+	}
+
+	doc, status := remoteLayer(r, true)
+	if got := retriesIn(doc); got != float64(7) {
+		t.Errorf("upload.retries = %v offline, want the cached 7", got)
+	}
+	if status.Err == nil {
+		t.Error("status does not report the failed fetch")
+	}
+
+	cached, err := readRemoteCache(&r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cached.LastError == "" || cached.due(&r) {
+		t.Error("failed fetch not recorded, so every load would wait for the server")
+	}
+}
+
+func TestRemoteCacheKeyChange(t *testing.T) {
+	s := newRemoteServer(t)
+	s.publish(t, s.key, 7, 1)
+
+	if _, err := FetchRemote(context.Background(), s.remote()); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	public, _, _ := ed25519.GenerateKey(nil)
+	r := s.remote()
+	r.PublicKey = base64.StdEncoding.EncodeToString(public)
+
+	if _, err := readRemoteCache(&r); err == nil {
+		t.Error("cached copy verified with a different key")
+	}
+	if doc, _ := remoteLayer(r, true); doc != nil {
+		t.Errorf("remote layer = %v after a key change, want none", doc)
+	}
+}
+
+func TestFetchRemoteRefusesRollback(t *testing.T) {
+	s := newRemoteServer(t)
+	s.publish(t, s.key, 7, 2)
+
+	if _, err := FetchRemote(context.Background(), s.remote()); err != nil {
+		t.Fatal(err)
+	}
+	s.publish(t, s.key, 3, 1)
+	if _, err := FetchRemote(context.Background(), s.remote()); err == nil {
+		t.Fatal("older serial was accepted")
+	}
+
+	doc, _ := remoteLayer(s.remote(), true)
+	if got := retriesIn(doc); got != float64(7) {
+		t.Errorf("upload.retries = %v, want 7 from the newer release", got)
+	}
+}
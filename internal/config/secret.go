@@ -0,0 +1,25 @@
+package config
+
+import "simple-uploader/internal/secret"
+
+// ResolveSecret fills SecretKey from the store secret_ref points to. A
+// plaintext secret_key is ignored when secret_ref is set.
+func (m *MinIOConfig) ResolveSecret() error {
+	if m.SecretRef == "" {
+		return nil
+	}
+	value, err := secret.Lookup(m.SecretRef)
+	if err != nil {
+		return err
+	}
+	m.SecretKey = value
+	return nil
+}
+
+// dropResolvedSecret clears a secret that came from a store, so Save keeps
+// only the reference
+func (m *MinIOConfig) dropResolvedSecret() {
+	if m.SecretRef != "" {
+		m.SecretKey = ""
+	}
+}
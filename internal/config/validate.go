@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"simple-uploader/internal/secret"
+)
+
+// Defaults applied by Normalize
+const (
+	DefaultPort        = 20080
+	DefaultDriveLetter = "Z"
+)
+
+// Part sizes accepted by S3 for multipart uploads
+const (
+	minPartSize = 5 * 1024 * 1024
+	maxPartSize = 5 * 1024 * 1024 * 1024
+)
+
+// FieldError is a problem with one config value, identified by its JSON
+// path such as "minio.endpoint" or "profiles.work.mount.port"
+type FieldError struct {
+	Path string
+	Msg  string
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Msg
+}
+
+// ValidationError lists every problem found in a config
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Errors) == 1 {
+		return "invalid config: " + e.Errors[0].Error()
+	}
+	lines := make([]string, len(e.Errors))
+	for i, fe := range e.Errors {
+		lines[i] = "  " + fe.Error()
+	}
+	return fmt.Sprintf("invalid config (%d problems):\n%s", len(e.Errors), strings.Join(lines, "\n"))
+}
+
+// validator collects field errors
+type validator struct {
+	errs []*FieldError
+}
+
+func (v *validator) add(path, format string, args ...any) {
+	v.errs = append(v.errs, &FieldError{Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// Normalize applies defaults and canonical forms to every section,
+// including all profiles
+func (c *Config) Normalize() {
+	c.MinIO.normalize()
+	c.Mount.normalize()
+	for name, p := range c.Profiles {
+		p.MinIO.normalize()
+		p.Mount.normalize()
+		c.Profiles[name] = p
+	}
+
+	c.Upload.Verify = strings.ToLower(strings.TrimSpace(c.Upload.Verify))
+	c.Upload.Encryption = strings.ToLower(strings.TrimSpace(c.Upload.Encryption))
+	c.Upload.StorageClass = strings.ToUpper(strings.TrimSpace(c.Upload.StorageClass))
+	for i := range c.Watch.Rules {
+		rule := &c.Watch.Rules[i]
+		rule.Folder = strings.TrimSpace(rule.Folder)
+		rule.StorageClass = strings.ToUpper(strings.TrimSpace(rule.StorageClass))
+	}
+	c.Remote.URL = strings.TrimSpace(c.Remote.URL)
+	c.Remote.PublicKey = strings.TrimSpace(c.Remote.PublicKey)
+}
+
+// normalize strips a scheme and trailing slash from the endpoint, turning
+// "https://" into use_ssl
+func (m *MinIOConfig) normalize() {
+	endpoint := strings.TrimSpace(m.Endpoint)
+	lower := strings.ToLower(endpoint)
+	switch {
+	case strings.HasPrefix(lower, "https://"):
+		endpoint = endpoint[len("https://"):]
+		m.UseSSL = true
+	case strings.HasPrefix(lower, "http://"):
+		endpoint = endpoint[len("http://"):]
+	}
+	m.Endpoint = strings.TrimRight(endpoint, "/")
+
+	m.Bucket = strings.TrimSpace(m.Bucket)
+	m.AccessKey = strings.TrimSpace(m.AccessKey)
+	m.Bootstrap = strings.ToLower(strings.TrimSpace(m.Bootstrap))
+	m.SecretRef = strings.TrimSpace(m.SecretRef)
+	m.CredentialProcess = strings.TrimSpace(m.CredentialProcess)
+}
+
+// normalize defaults the mount type, port and drive letter, and turns
+// "z:" into "Z"
+func (m *MountConfig) normalize() {
+	m.Type = strings.ToLower(strings.TrimSpace(m.Type))
+	if m.Type == "" {
+		m.Type = "webdav"
+	}
+	if m.Port == 0 {
+		m.Port = DefaultPort
+	}
+
+	letter := strings.ToUpper(strings.TrimSpace(m.DriveLetter))
+	letter = strings.TrimSuffix(letter, `\`)
+	letter = strings.TrimSuffix(letter, ":")
+	if letter == "" {
+		letter = DefaultDriveLetter
+	}
+	m.DriveLetter = letter
+}
+
+// Validate checks the settings in effect, that is the active profile's
+// minio and mount sections plus the shared sections, and reports every
+// problem with its field path
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	c.MinIO.validate(v, c.sectionPath("minio"))
+	c.Mount.validate(v, c.sectionPath("mount"))
+
+	if c.DefaultProfile != "" {
+		if _, ok := c.Profiles[c.DefaultProfile]; !ok {
+			v.add("default_profile", "profile %q is not defined in profiles", c.DefaultProfile)
+		}
+	}
+
+	c.Upload.validate(v, "upload")
+	c.Policy.validate(v, "policy")
+	c.Queue.validate(v, "queue")
+	c.Watch.validate(v, "watch")
+	c.Remote.validate(v, "remote")
+
+	return v.err()
+}
+
+// sectionPath is the field path of the minio or mount section in effect
+func (c *Config) sectionPath(section string) string {
+	if c.activeProfile != "" {
+		return "profiles." + c.activeProfile + "." + section
+	}
+	return section
+}
+
+// bucketName follows the S3 bucket naming rules
+var bucketName = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+func (m *MinIOConfig) validate(v *validator, path string) {
+	if m.Endpoint == "" {
+		v.add(path+".endpoint", "is required, e.g. \"minio.example.com:9000\"")
+	} else if strings.Contains(m.Endpoint, "/") {
+		v.add(path+".endpoint", "must be host[:port] without a path, got %q", m.Endpoint)
+	} else if host, port, err := net.SplitHostPort(m.Endpoint); err == nil {
+		if host == "" {
+			v.add(path+".endpoint", "host is missing in %q", m.Endpoint)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			v.add(path+".endpoint", "port %q is not between 1 and 65535", port)
+		}
+	}
+
+	if m.CredentialProcess == "" {
+		if m.AccessKey == "" {
+			v.add(path+".access_key", "is required, or set credential_process")
+		}
+		if m.SecretRef != "" {
+			if _, err := secret.ParseRef(m.SecretRef); err != nil {
+				v.add(path+".secret_ref", "%v", err)
+			}
+		} else if m.SecretKey == "" {
+			v.add(path+".secret_key", "is required, or set secret_ref or credential_process")
+		}
+	}
+
+	if m.Bucket == "" {
+		v.add(path+".bucket", "is required")
+	} else if !bucketName.MatchString(m.Bucket) || strings.Contains(m.Bucket, "..") {
+		v.add(path+".bucket", "%q is not a valid bucket name (3-63 lowercase letters, digits, dots and hyphens)", m.Bucket)
+	}
+
+	switch m.Bootstrap {
+	case "", "create", "require", "skip":
+	default:
+		v.add(path+".bootstrap", "must be create, require or skip, got %q", m.Bootstrap)
+	}
+}
+
+func (m *MountConfig) validate(v *validator, path string) {
+	switch m.Type {
+	case "webdav", "winfsp":
+	default:
+		v.add(path+".type", "must be webdav or winfsp, got %q", m.Type)
+	}
+
+	if m.Port < 1 || m.Port > 65535 {
+		v.add(path+".port", "must be between 1 and 65535, got %d", m.Port)
+	}
+
+	if len(m.DriveLetter) != 1 || m.DriveLetter[0] < 'A' || m.DriveLetter[0] > 'Z' {
+		v.add(path+".drive_letter", "must be a single letter A-Z, got %q", m.DriveLetter)
+	}
+}
+
+func (u *UploadConfig) validate(v *validator, path string) {
+	switch u.Verify {
+	case "", "crc32c", "sha256":
+	default:
+		v.add(path+".verify", "must be empty, crc32c or sha256, got %q", u.Verify)
+	}
+
+	switch u.Encryption {
+	case "", "sse-s3":
+	default:
+		v.add(path+".encryption", "must be empty or sse-s3, got %q", u.Encryption)
+	}
+
+	if u.PartSize != 0 && (u.PartSize < minPartSize || u.PartSize > maxPartSize) {
+		v.add(path+".part_size", "must be between 5 MiB and 5 GiB (%d to %d bytes), got %d",
+			uint64(minPartSize), uint64(maxPartSize), u.PartSize)
+	}
+
+	if len(u.Tags) > 10 {
+		v.add(path+".tags", "at most 10 tags are allowed, got %d", len(u.Tags))
+	}
+
+	nonNegative(v, path+".retries", u.Retries)
+	nonNegative(v, path+".timeout_seconds", u.TimeoutSeconds)
+	nonNegative(v, path+".min_speed_kbps", u.MinSpeedKBps)
+	nonNegative(v, path+".stall_seconds", u.StallSeconds)
+}
+
+func (p *PolicyConfig) validate(v *validator, path string) {
+	for i, pattern := range p.ForbiddenNames {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			v.add(fmt.Sprintf("%s.forbidden_names[%d]", path, i), "invalid pattern %q", pattern)
+		}
+	}
+}
+
+func (q *QueueConfig) validate(v *validator, path string) {
+	nonNegative(v, path+".interval_seconds", q.IntervalSeconds)
+	nonNegative(v, path+".max_attempts", q.MaxAttempts)
+}
+
+func (w *WatchConfig) validate(v *validator, path string) {
+	nonNegative(v, path+".stable_seconds", w.StableSeconds)
+
+	seen := make(map[string]int)
+	for i, rule := range w.Rules {
+		rulePath := fmt.Sprintf("%s.rules[%d]", path, i)
+		if rule.Folder == "" {
+			v.add(rulePath+".folder", "is required")
+			continue
+		}
+		if !filepath.IsAbs(rule.Folder) {
+			v.add(rulePath+".folder", "must be an absolute path, got %q", rule.Folder)
+		}
+		key := strings.ToLower(filepath.Clean(rule.Folder))
+		if j, ok := seen[key]; ok {
+			v.add(rulePath+".folder", "is already watched by %s.rules[%d]", path, j)
+		}
+		seen[key] = i
+		if len(rule.Tags) > 10 {
+			v.add(rulePath+".tags", "at most 10 tags are allowed, got %d", len(rule.Tags))
+		}
+	}
+}
+
+func nonNegative(v *validator, path string, n int) {
+	if n < 0 {
+		v.add(path, "must not be negative, got %d", n)
+	}
+}
@@ -0,0 +1,239 @@
+package filter
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// IgnoreFile is the name of the per-folder rule file. Its rules apply to the
+// folder it is in and everything below it. The file itself is never uploaded.
+const IgnoreFile = ".minioignore"
+
+// rule is one parsed gitignore-style pattern
+type rule struct {
+	base     string   // folder the rule was defined in, relative to the root
+	segments []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes what an earlier rule excluded
+	dirOnly  bool     // "pattern/" only matches folders
+	anchored bool     // contains a slash, so matched from base rather than at any depth
+}
+
+// Matcher decides which paths under a root folder are excluded, using the
+// global rules from config followed by the .minioignore files found in the
+// root and its subfolders. As in gitignore, the last matching rule wins and
+// nothing inside an excluded folder can be re-included.
+type Matcher struct {
+	root   string
+	global []rule
+
+	mu      sync.Mutex
+	files   map[string][]rule // rules from each folder's ignore file
+	folders map[string]bool   // exclusion result per folder
+}
+
+// New returns a matcher for paths under root. root may be "" when only the
+// global patterns should be used.
+func New(root string, patterns []string) *Matcher {
+	return &Matcher{
+		root:    root,
+		global:  parse("", patterns),
+		files:   make(map[string][]rule),
+		folders: make(map[string]bool),
+	}
+}
+
+// Excluded reports whether the slash-separated path rel, relative to the
+// root, is excluded either by itself or through one of its parent folders
+func (m *Matcher) Excluded(rel string, isDir bool) bool {
+	if m == nil {
+		return false
+	}
+	rel = strings.Trim(path.Clean("/"+rel), "/")
+	if rel == "" {
+		return false
+	}
+	if !isDir && path.Base(rel) == IgnoreFile {
+		return true
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir := ""
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = path.Join(dir, part)
+		excluded, ok := m.folders[dir]
+		if !ok {
+			excluded = m.match(dir, true)
+			m.folders[dir] = excluded
+		}
+		if excluded {
+			return true
+		}
+	}
+	return m.match(rel, isDir)
+}
+
+// ExcludedFile reports whether a single file on disk is excluded. The global
+// rules are matched from the file's own folder, as for an upload of just that
+// file, while the ignore files of every folder up to the volume root apply as
+// they would to a folder upload from any of them.
+func ExcludedFile(filePath string, patterns []string) bool {
+	abs, err := filepath.Abs(filePath)
+	if err != nil {
+		return New(filepath.Dir(filePath), patterns).Excluded(filepath.Base(filePath), false)
+	}
+	root := filepath.VolumeName(abs) + string(filepath.Separator)
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return New(filepath.Dir(filePath), patterns).Excluded(filepath.Base(filePath), false)
+	}
+	rel = filepath.ToSlash(rel)
+
+	m := New(root, nil)
+	if dir := path.Dir(rel); dir != "." {
+		m.global = parse(dir, patterns)
+	} else {
+		m.global = parse("", patterns)
+	}
+	return m.Excluded(rel, false)
+}
+
+// match applies the global rules, then the ignore files from the root down
+// to rel's folder, and returns the verdict of the last matching rule
+func (m *Matcher) match(rel string, isDir bool) bool {
+	excluded := false
+	apply := func(rules []rule) {
+		for _, r := range rules {
+			if r.matches(rel, isDir) {
+				excluded = !r.negate
+			}
+		}
+	}
+
+	apply(m.global)
+	if m.root == "" {
+		return excluded
+	}
+
+	apply(m.ignoreFile(""))
+	dir := ""
+	parts := strings.Split(rel, "/")
+	for _, part := range parts[:len(parts)-1] {
+		dir = path.Join(dir, part)
+		apply(m.ignoreFile(dir))
+	}
+	return excluded
+}
+
+// ignoreFile returns the rules from dir's ignore file, read once
+func (m *Matcher) ignoreFile(dir string) []rule {
+	if rules, ok := m.files[dir]; ok {
+		return rules
+	}
+
+	var rules []rule
+	f, err := os.Open(filepath.Join(m.root, filepath.FromSlash(dir), IgnoreFile))
+	if err == nil {
+		var patterns []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			patterns = append(patterns, scanner.Text())
+		}
+		f.Close()
+		rules = parse(dir, patterns)
+	}
+
+	m.files[dir] = rules
+	return rules
+}
+
+// parse turns gitignore-style lines into rules. Blank lines and lines
+// starting with "#" are skipped; "\#" and "\!" escape a leading character.
+func parse(base string, patterns []string) []rule {
+	var rules []rule
+	for _, p := range patterns {
+		p = strings.TrimRight(p, " \t\r")
+		if p == "" || strings.HasPrefix(p, "#") {
+			continue
+		}
+
+		r := rule{base: base}
+		if strings.HasPrefix(p, "!") {
+			r.negate = true
+			p = p[1:]
+		} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+			p = p[1:]
+		}
+		if strings.HasSuffix(p, "/") {
+			r.dirOnly = true
+			p = strings.TrimRight(p, "/")
+		}
+		if strings.Contains(p, "/") {
+			r.anchored = true
+			p = strings.TrimPrefix(p, "/")
+		}
+		if p == "" {
+			continue
+		}
+
+		r.segments = strings.Split(p, "/")
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matches reports whether the rule applies to rel, relative to the root
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	if r.base != "" {
+		if !strings.HasPrefix(rel, r.base+"/") {
+			return false
+		}
+		rel = strings.TrimPrefix(rel, r.base+"/")
+	}
+
+	parts := strings.Split(rel, "/")
+	if !r.anchored {
+		// A pattern without a slash matches a name at any depth
+		return len(r.segments) == 1 && matchSegment(r.segments[0], parts[len(parts)-1])
+	}
+	return matchSegments(r.segments, parts)
+}
+
+// matchSegments matches path segments against pattern segments, where "**"
+// stands for any number of folders
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				// A trailing "**" matches what is inside, not the folder itself
+				return len(parts) > 0
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 || !matchSegment(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func matchSegment(pattern, name string) bool {
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
@@ -0,0 +1,52 @@
+package filter
+
+import "strings"
+
+// RcloneArgs translates global patterns into rclone --filter arguments.
+// rclone stops at the first matching rule while gitignore keeps the last,
+// so the order is reversed. Per-folder ignore files have no rclone
+// equivalent and are not translated.
+func RcloneArgs(patterns []string) []string {
+	var args []string
+	for i := len(patterns) - 1; i >= 0; i-- {
+		for _, f := range rcloneFilters(patterns[i]) {
+			args = append(args, "--filter", f)
+		}
+	}
+	return args
+}
+
+// rcloneFilters converts one pattern to rclone "+ rule" or "- rule" filters
+func rcloneFilters(p string) []string {
+	p = strings.TrimRight(p, " \t\r")
+	if p == "" || strings.HasPrefix(p, "#") {
+		return nil
+	}
+
+	sign := "- "
+	if strings.HasPrefix(p, "!") {
+		sign = "+ "
+		p = p[1:]
+	} else if strings.HasPrefix(p, `\#`) || strings.HasPrefix(p, `\!`) {
+		p = p[1:]
+	}
+
+	dirOnly := strings.HasSuffix(p, "/")
+	p = strings.TrimRight(p, "/")
+	if p == "" {
+		return nil
+	}
+
+	// rclone matches slashless patterns at any depth like gitignore, but
+	// patterns with a slash only from the root when they start with one
+	if strings.Contains(p, "/") && !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+
+	// A gitignore pattern also covers the contents of a matching folder;
+	// rclone needs a separate rule for that
+	if dirOnly {
+		return []string{sign + p + "/**"}
+	}
+	return []string{sign + p, sign + p + "/**"}
+}
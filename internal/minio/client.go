@@ -2,23 +2,82 @@ package minio
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"path/filepath"
 	"simple-uploader/internal/config"
+	"simple-uploader/internal/policy"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/minio/minio-go/v7"
-	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+	"github.com/minio/minio-go/v7/pkg/tags"
 )
 
+// defaultStreamPartSize is used for uploads of unknown size when no part
+// size is configured. minio-go would otherwise buffer ~500 MiB per part.
+const defaultStreamPartSize = 64 * 1024 * 1024
+
 type Client struct {
-	client *minio.Client
-	bucket string
+	client    *minio.Client
+	endpoint  string
+	bucket    string
+	bootstrap string
+	opts      UploadOptions
+	policy    *policy.Policy
+}
+
+// Bucket bootstrap modes, deciding what EnsureBucket does about a missing bucket
+const (
+	BootstrapCreate  = "create"  // create it
+	BootstrapRequire = "require" // report it as an error
+	BootstrapSkip    = "skip"    // do not check at all
+)
+
+// UploadOptions controls how files are uploaded
+type UploadOptions struct {
+	Verify     string            // "", "crc32c" or "sha256"
+	Metadata   map[string]string // user metadata sent with every object
+	Encryption string            // "" or "sse-s3"
+	PartSize   uint64            // multipart part size in bytes, 0 for default
+
+	StorageClass string            // storage class for new objects, "" for the server default
+	Tags         map[string]string // object tags set on every upload
+
+	// StallTimeout cancels an upload that sends nothing for this long, 0 to wait forever
+	StallTimeout time.Duration
+
+	// Progress is called with the bytes sent so far for an object. Parts
+	// of a multipart upload are sent in parallel, so it may be called from
+	// several goroutines at once and see the totals out of order.
+	Progress func(objectName string, sent int64)
+
+	// MatchETag makes the upload fail unless the object it replaces still
+	// has this ETag (If-Match), "" to overwrite unconditionally
+	MatchETag string
+
+	// Policy is checked before every file or stream is sent
+	Policy config.PolicyConfig
 }
 
 // NewClient creates a new MinIO client from config
 func NewClient(cfg *config.MinIOConfig) (*Client, error) {
+	bootstrap := strings.ToLower(cfg.Bootstrap)
+	switch bootstrap {
+	case "":
+		bootstrap = BootstrapCreate
+	case BootstrapCreate, BootstrapRequire, BootstrapSkip:
+	default:
+		return nil, fmt.Errorf("unknown bucket bootstrap mode %q (use create, require or skip)", cfg.Bootstrap)
+	}
+
 	client, err := minio.New(cfg.Endpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Creds:  credentialsFor(cfg),
 		Secure: cfg.UseSSL,
 	})
 	if err != nil {
@@ -26,22 +85,302 @@ func NewClient(cfg *config.MinIOConfig) (*Client, error) {
 	}
 
 	return &Client{
-		client: client,
-		bucket: cfg.Bucket,
+		client:    client,
+		endpoint:  cfg.Endpoint,
+		bucket:    cfg.Bucket,
+		bootstrap: bootstrap,
 	}, nil
 }
 
+// Bucket returns the bucket the client works on
+func (c *Client) Bucket() string {
+	return c.bucket
+}
+
+// OptionsFromConfig returns the upload options configured in the upload
+// section, held to the upload policy
+func OptionsFromConfig(cfg *config.Config) UploadOptions {
+	return UploadOptions{
+		Verify:     cfg.Upload.Verify,
+		Metadata:   cfg.Upload.Metadata,
+		Encryption: cfg.Upload.Encryption,
+		PartSize:   cfg.Upload.PartSize,
+
+		StorageClass: cfg.Upload.StorageClass,
+		Tags:         cfg.Upload.Tags,
+		StallTimeout: time.Duration(cfg.Upload.StallSeconds) * time.Second,
+
+		Policy: cfg.Policy,
+	}
+}
+
+// WithMetadata returns a copy of the options with extra user metadata
+// merged over the configured metadata
+func (o UploadOptions) WithMetadata(extra map[string]string) UploadOptions {
+	if len(extra) == 0 {
+		return o
+	}
+
+	merged := make(map[string]string, len(o.Metadata)+len(extra))
+	for k, v := range o.Metadata {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	o.Metadata = merged
+	return o
+}
+
+// WithTags returns a copy of the options with extra tags merged over the
+// configured tags
+func (o UploadOptions) WithTags(extra map[string]string) UploadOptions {
+	if len(extra) == 0 {
+		return o
+	}
+
+	merged := make(map[string]string, len(o.Tags)+len(extra))
+	for k, v := range o.Tags {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	o.Tags = merged
+	return o
+}
+
+// WithStorageClass returns a copy of the options using the given storage
+// class, or the options unchanged when class is ""
+func (o UploadOptions) WithStorageClass(class string) UploadOptions {
+	if class != "" {
+		o.StorageClass = class
+	}
+	return o
+}
+
+// SetUploadOptions sets the options used by subsequent uploads
+func (c *Client) SetUploadOptions(opts UploadOptions) error {
+	if _, err := checksumType(opts.Verify); err != nil {
+		return err
+	}
+	switch opts.Encryption {
+	case "", "sse-s3":
+	default:
+		return fmt.Errorf("unknown encryption %q (use sse-s3)", opts.Encryption)
+	}
+	if _, err := tags.NewTags(opts.Tags, true); err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	pol, err := policy.New(&opts.Policy)
+	if err != nil {
+		return fmt.Errorf("invalid upload policy: %w", err)
+	}
+	c.opts = opts
+	c.policy = pol
+	return nil
+}
+
+// checkPolicy refuses an object the upload policy does not allow. size is
+// -1 for streams, which are held to the size limit while they are read.
+func (c *Client) checkPolicy(objectName string, size int64) error {
+	if c.policy == nil {
+		return nil
+	}
+	if v := c.policy.CheckFile(objectName, size); v != nil {
+		return fmt.Errorf("%s: %w", objectName, v)
+	}
+	return nil
+}
+
+// UploadOptions returns the options used for uploads
+func (c *Client) UploadOptions() UploadOptions {
+	return c.opts
+}
+
+// WithUploadOptions returns a client sharing the connection of c that
+// uploads with different options
+func (c *Client) WithUploadOptions(opts UploadOptions) (*Client, error) {
+	clone := *c
+	if err := clone.SetUploadOptions(opts); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// putOptions builds the PutObject options shared by file and stream uploads
+func putOptions(o UploadOptions, objectName string) minio.PutObjectOptions {
+	opts := minio.PutObjectOptions{
+		PartSize: o.PartSize,
+	}
+
+	if len(o.Metadata) > 0 {
+		opts.UserMetadata = make(map[string]string, len(o.Metadata))
+		for k, v := range o.Metadata {
+			opts.UserMetadata[k] = v
+		}
+	}
+
+	if o.Encryption == "sse-s3" {
+		opts.ServerSideEncryption = encrypt.NewSSE()
+	}
+
+	if o.StorageClass != "" {
+		opts.StorageClass = strings.ToUpper(o.StorageClass)
+	}
+	if len(o.Tags) > 0 {
+		opts.UserTags = make(map[string]string, len(o.Tags))
+		for k, v := range o.Tags {
+			opts.UserTags[k] = v
+		}
+	}
+
+	if o.Progress != nil {
+		opts.Progress = &progressReader{objectName: objectName, fn: o.Progress}
+	}
+
+	if o.MatchETag != "" {
+		opts.SetMatchETag(o.MatchETag)
+	}
+
+	return opts
+}
+
+// progressReader adapts minio-go progress reporting to a callback.
+// minio-go calls Read with a buffer sized to the bytes just sent, from
+// each part upload worker.
+type progressReader struct {
+	objectName string
+	sent       int64
+	fn         func(objectName string, sent int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	p.fn(p.objectName, atomic.AddInt64(&p.sent, int64(len(b))))
+	return len(b), nil
+}
+
 // UploadFile uploads a single file to the bucket root
 func (c *Client) UploadFile(ctx context.Context, filePath string) error {
 	// Use only the filename, upload to bucket root
-	objectName := filepath.Base(filePath)
+	return c.UploadFileAs(ctx, filePath, filepath.Base(filePath))
+}
+
+// UploadFileAs uploads a single file under the given object name
+func (c *Client) UploadFileAs(ctx context.Context, filePath, objectName string) error {
+	return c.UploadFileWithMetadata(ctx, filePath, objectName, nil)
+}
+
+// UploadFileWithMetadata uploads a single file under the given object name,
+// adding user metadata for this object only
+func (c *Client) UploadFileWithMetadata(ctx context.Context, filePath, objectName string, meta map[string]string) error {
+	_, err := c.putFile(ctx, filePath, objectName, meta)
+	return err
+}
+
+// UploadInfo describes an object after a successful upload
+type UploadInfo struct {
+	Key  string
+	Size int64
+	ETag string
+}
+
+// PutFile uploads a single file under the given object name and returns
+// what was stored
+func (c *Client) PutFile(ctx context.Context, filePath, objectName string) (UploadInfo, error) {
+	return c.putFile(ctx, filePath, objectName, nil)
+}
 
-	_, err := c.client.FPutObject(ctx, c.bucket, objectName, filePath, minio.PutObjectOptions{})
+func (c *Client) putFile(ctx context.Context, filePath, objectName string, meta map[string]string) (UploadInfo, error) {
+	fi, err := os.Stat(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to upload %s: %w", filePath, err)
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, err)
+	}
+	if err := c.checkPolicy(objectName, fi.Size()); err != nil {
+		return UploadInfo{}, err
 	}
 
-	return nil
+	opts := c.opts.WithMetadata(meta)
+	if opts.Verify != "" {
+		return c.uploadVerified(ctx, opts, filePath, objectName)
+	}
+
+	ctx, opts, stop := guardStall(ctx, opts)
+	defer stop()
+
+	info, err := c.client.FPutObject(ctx, c.bucket, objectName, filePath, putOptions(opts, objectName))
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, stallError(ctx, err))
+	}
+
+	return uploadInfo(info), nil
+}
+
+// UploadStream uploads a reader of unknown size, such as stdin or a named
+// pipe, as a multipart upload under the given object name
+func (c *Client) UploadStream(ctx context.Context, r io.Reader, objectName string) error {
+	_, err := c.PutStream(ctx, r, objectName)
+	return err
+}
+
+// PutStream is UploadStream returning what was stored
+func (c *Client) PutStream(ctx context.Context, r io.Reader, objectName string) (UploadInfo, error) {
+	if err := c.checkPolicy(objectName, -1); err != nil {
+		return UploadInfo{}, err
+	}
+	if c.policy != nil {
+		r = c.policy.LimitReader(r)
+	}
+
+	ctx, o, r, stop := guardStallSource(ctx, c.opts, r)
+	defer stop()
+
+	opts := putOptions(o, objectName)
+	if opts.PartSize == 0 {
+		opts.PartSize = defaultStreamPartSize
+	}
+
+	// Streams are always sent in parts, each with a CRC32C, so the
+	// checksum of the parts is computed as they are read
+	var sum *partChecksum
+	if c.opts.Verify != "" {
+		sum = newPartChecksum(int64(opts.PartSize))
+		r = io.TeeReader(r, sum)
+	}
+
+	counter := &countingReader{r: r}
+	info, err := c.client.PutObject(ctx, c.bucket, objectName, counter, -1, opts)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", objectName, stallError(ctx, err))
+	}
+
+	if c.opts.Verify != "" {
+		if err := c.verifyParts(ctx, objectName, objectName, counter.n, sum.Encoded()); err != nil {
+			return UploadInfo{}, err
+		}
+	}
+
+	return uploadInfo(info), nil
+}
+
+func uploadInfo(info minio.UploadInfo) UploadInfo {
+	return UploadInfo{
+		Key:  info.Key,
+		Size: info.Size,
+		ETag: strings.Trim(info.ETag, `"`),
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += int64(n)
+	return n, err
 }
 
 // UploadFiles uploads multiple files to the bucket root
@@ -59,18 +398,118 @@ func (c *Client) UploadFiles(ctx context.Context, filePaths []string) (successes
 	return successes, failures
 }
 
-// EnsureBucket checks if bucket exists, creates if not
+// Ping checks that the server can be reached with the configured credentials
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.client.BucketExists(ctx, c.bucket)
+	return err
+}
+
+// IsOffline reports whether err means the server could not be reached,
+// as opposed to an error response from the server or a local error
+func IsOffline(err error) bool {
+	return minio.IsNetworkOrHostDown(err, false)
+}
+
+// IsRetryable reports whether an upload that failed with err may succeed
+// when tried again: the server was unreachable or busy, or the upload stalled
+func IsRetryable(err error) bool {
+	if IsOffline(err) || errors.Is(err, ErrStalled) {
+		return true
+	}
+	var resp minio.ErrorResponse
+	if errors.As(err, &resp) {
+		switch resp.Code {
+		case "InternalError", "ServiceUnavailable", "SlowDown", "RequestTimeout":
+			return true
+		}
+		return resp.StatusCode >= 500
+	}
+	return false
+}
+
+// IsPermanent reports whether an upload failed because the upload policy
+// or the server refused it, such as AccessDenied or NoSuchBucket, which
+// retrying the same upload cannot fix
+func IsPermanent(err error) bool {
+	if errors.Is(err, policy.ErrRejected) {
+		return true
+	}
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && !IsRetryable(err)
+}
+
+// IsNotFound reports whether err means the object does not exist
+func IsNotFound(err error) bool {
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && (resp.Code == "NoSuchKey" || resp.StatusCode == 404)
+}
+
+// IsPreconditionFailed reports whether a conditional request failed
+// because the object changed
+func IsPreconditionFailed(err error) bool {
+	var resp minio.ErrorResponse
+	return errors.As(err, &resp) && (resp.Code == "PreconditionFailed" || resp.StatusCode == 412)
+}
+
+// ErrorCode returns a short machine-readable code for an upload error: the
+// S3 error code for server errors, or a local category otherwise
+func ErrorCode(err error) string {
+	var resp minio.ErrorResponse
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrVerifyMismatch):
+		return "verify_mismatch"
+	case errors.Is(err, ErrStalled):
+		return "stalled"
+	case errors.Is(err, ErrBucketMissing):
+		return "bucket_missing"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.As(err, &resp) && resp.Code != "":
+		return resp.Code
+	case IsOffline(err):
+		return "offline"
+	case errors.Is(err, fs.ErrNotExist):
+		return "not_found"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission_denied"
+	}
+	return "error"
+}
+
+// ErrBucketMissing is returned by EnsureBucket when the bucket does not
+// exist and the bootstrap mode does not allow creating it
+var ErrBucketMissing = errors.New("bucket does not exist")
+
+// EnsureBucket makes sure the bucket is there according to the bootstrap
+// mode: create it when missing, require it to exist, or skip the check
 func (c *Client) EnsureBucket(ctx context.Context) error {
+	if c.bootstrap == BootstrapSkip {
+		return nil
+	}
+
 	exists, err := c.client.BucketExists(ctx, c.bucket)
 	if err != nil {
 		return fmt.Errorf("failed to check bucket: %w", err)
 	}
+	if exists {
+		return nil
+	}
+
+	if c.bootstrap == BootstrapRequire {
+		return fmt.Errorf("%w: %q on %s; ask the administrator to create it, or set minio.bootstrap to \"create\"",
+			ErrBucketMissing, c.bucket, c.endpoint)
+	}
 
-	if !exists {
-		err = c.client.MakeBucket(ctx, c.bucket, minio.MakeBucketOptions{})
-		if err != nil {
-			return fmt.Errorf("failed to create bucket: %w", err)
+	if err := c.client.MakeBucket(ctx, c.bucket, minio.MakeBucketOptions{}); err != nil {
+		if ErrorCode(err) == "AccessDenied" {
+			return fmt.Errorf("bucket %q does not exist and your access key may not create buckets; ask the administrator to create it: %w",
+				c.bucket, err)
 		}
+		return fmt.Errorf("failed to create bucket: %w", err)
 	}
 
 	return nil
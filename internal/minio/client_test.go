@@ -0,0 +1,142 @@
+package minio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"simple-uploader/internal/config"
+)
+
+// fakeS3 answers just enough of the S3 API for multipart uploads
+type fakeS3 struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	parts map[string]int64 // part number to bytes received
+}
+
+func newFakeS3(t *testing.T) *fakeS3 {
+	t.Helper()
+	s := &fakeS3{parts: make(map[string]int64)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	switch {
+	case q.Has("location"):
+		fmt.Fprint(w, `<LocationConstraint xmlns="http://s3.amazonaws.com/doc/2006-03-01/">us-east-1</LocationConstraint>`)
+	case r.Method == http.MethodPost && q.Has("uploads"):
+		fmt.Fprint(w, `<InitiateMultipartUploadResult><Bucket>test</Bucket><Key>big.bin</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`)
+	case r.Method == http.MethodPut && q.Has("partNumber"):
+		n, err := io.Copy(io.Discard, r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.parts[q.Get("partNumber")] = n
+		s.mu.Unlock()
+		w.Header().Set("ETag", `"part`+q.Get("partNumber")+`"`)
+	case r.Method == http.MethodPost && q.Has("uploadId"):
+		_, _ = io.Copy(io.Discard, r.Body)
+		fmt.Fprint(w, `<CompleteMultipartUploadResult><Bucket>test</Bucket><Key>big.bin</Key><ETag>"done-4"</ETag></CompleteMultipartUploadResult>`)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+r.URL.String(), http.StatusNotImplemented)
+	}
+}
+
+// TestUploadProgressParallelParts uploads a file in four parts, which
+// minio-go sends on parallel workers, with the stall guard and a progress
+// callback installed. Run with -race.
+func TestUploadProgressParallelParts(t *testing.T) {
+	s := newFakeS3(t)
+
+	const partSize = 5 << 20
+	const size = 4 * partSize
+	filePath := filepath.Join(t.TempDir(), "big.bin")
+	if err := os.WriteFile(filePath, make([]byte, size), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := NewClient(&config.MinIOConfig{
+		Endpoint:  strings.TrimPrefix(s.URL, "http://"),
+		AccessKey: "test",
+		SecretKey: "testsecret",
+		Bucket:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var highest int64
+	calls := 0
+	err = client.SetUploadOptions(UploadOptions{
+		PartSize:     partSize,
+		StallTimeout: time.Minute,
+		Progress: func(objectName string, sent int64) {
+			mu.Lock()
+			defer mu.Unlock()
+			calls++
+			if sent > highest {
+				highest = sent
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.UploadFileAs(context.Background(), filePath, "big.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(s.parts) != 4 {
+		t.Errorf("server received %d parts, want 4", len(s.parts))
+	}
+	if calls == 0 {
+		t.Fatal("progress was never reported")
+	}
+	if highest != size {
+		t.Errorf("progress reached %d bytes, want %d", highest, size)
+	}
+}
+
+// TestProgressReaderConcurrent calls the progress hook from several part
+// workers at once, as minio-go does. Run with -race.
+func TestProgressReaderConcurrent(t *testing.T) {
+	const workers, reads, chunk = 4, 1000, 16 << 10
+	p := &progressReader{objectName: "big.bin", fn: func(string, int64) {}}
+
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			b := make([]byte, chunk)
+			for j := 0; j < reads; j++ {
+				_, _ = p.Read(b)
+			}
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	if want := int64(workers * reads * chunk); p.sent != want {
+		t.Errorf("sent = %d, want %d", p.sent, want)
+	}
+}
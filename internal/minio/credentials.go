@@ -0,0 +1,42 @@
+package minio
+
+import (
+	"context"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/secret"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// processProvider feeds minio-go with keys from a credential process. The
+// process caches them, so Retrieve only runs the command near expiry.
+type processProvider struct {
+	process *secret.Process
+}
+
+func (p *processProvider) Retrieve() (credentials.Value, error) {
+	c, err := p.process.Get(context.Background())
+	if err != nil {
+		return credentials.Value{}, err
+	}
+	return credentials.Value{
+		AccessKeyID:     c.AccessKeyID,
+		SecretAccessKey: c.SecretAccessKey,
+		SessionToken:    c.SessionToken,
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+func (p *processProvider) IsExpired() bool {
+	return p.process.Expired()
+}
+
+// credentialsFor returns static keys, or a refreshing provider when the
+// config names a credential process
+func credentialsFor(cfg *config.MinIOConfig) *credentials.Credentials {
+	if cfg.CredentialProcess != "" {
+		return credentials.New(&processProvider{process: secret.ProcessFor(cfg.CredentialProcess)})
+	}
+	return credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, "")
+}
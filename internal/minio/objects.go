@@ -0,0 +1,178 @@
+package minio
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/tags"
+)
+
+// Metadata keys shared with rclone, so objects written through the mounted
+// drive and by sync can be compared the same way
+const (
+	MetaMtime = "Mtime"     // modification time as fractional unix seconds
+	MetaMD5   = "Md5chksum" // base64 MD5 of the content, for multipart uploads
+)
+
+// Object describes a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	ModTime      time.Time // local mtime recorded at upload, zero if unknown
+	MD5          string    // hex MD5 of the content, "" if unknown
+}
+
+// ListObjects returns every object under prefix, skipping folder markers
+func (c *Client) ListObjects(ctx context.Context, prefix string) ([]Object, error) {
+	var result []Object
+	objects := c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithMetadata: true,
+	})
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			continue
+		}
+		result = append(result, toObject(obj))
+	}
+	return result, nil
+}
+
+// StatObject returns information about a single object
+func (c *Client) StatObject(ctx context.Context, key string) (Object, error) {
+	info, err := c.client.StatObject(ctx, c.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return Object{}, fmt.Errorf("failed to stat %s: %w", key, err)
+	}
+	return toObject(info), nil
+}
+
+// RemoveObject deletes a single object
+func (c *Client) RemoveObject(ctx context.Context, key string) error {
+	if err := c.client.RemoveObject(ctx, c.bucket, key, minio.RemoveObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// DownloadFile downloads an object to a local file
+func (c *Client) DownloadFile(ctx context.Context, key, filePath string) error {
+	if err := c.client.FGetObject(ctx, c.bucket, key, filePath, minio.GetObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return nil
+}
+
+// DownloadFileIfMatch downloads an object to a local file only while it
+// still has the given ETag, failing with a precondition error otherwise
+func (c *Client) DownloadFileIfMatch(ctx context.Context, key, etag, filePath string) error {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetMatchETag(etag); err != nil {
+		return err
+	}
+	if err := c.client.FGetObject(ctx, c.bucket, key, filePath, opts); err != nil {
+		return fmt.Errorf("failed to download %s: %w", key, err)
+	}
+	return nil
+}
+
+// FileMetadata returns the metadata recorded for a local file at upload
+func FileMetadata(modTime time.Time, md5Hex string) map[string]string {
+	meta := map[string]string{
+		MetaMtime: formatMtime(modTime),
+	}
+	if raw, err := hex.DecodeString(md5Hex); err == nil && len(raw) > 0 {
+		meta[MetaMD5] = base64.StdEncoding.EncodeToString(raw)
+	}
+	return meta
+}
+
+func toObject(info minio.ObjectInfo) Object {
+	obj := Object{
+		Key:          info.Key,
+		Size:         info.Size,
+		ETag:         strings.Trim(info.ETag, `"`),
+		LastModified: info.LastModified,
+	}
+
+	if v := userMetadata(info, MetaMtime); v != "" {
+		obj.ModTime = parseMtime(v)
+	}
+
+	// A plain ETag is the MD5 of the content; multipart ETags contain a dash
+	if len(obj.ETag) == 32 && !strings.Contains(obj.ETag, "-") {
+		obj.MD5 = obj.ETag
+	} else if v := userMetadata(info, MetaMD5); v != "" {
+		if raw, err := base64.StdEncoding.DecodeString(v); err == nil {
+			obj.MD5 = hex.EncodeToString(raw)
+		}
+	}
+
+	return obj
+}
+
+// userMetadata looks up a user metadata value in listing or stat results
+func userMetadata(info minio.ObjectInfo, key string) string {
+	for k, v := range info.UserMetadata {
+		k = strings.TrimPrefix(strings.ToLower(k), "x-amz-meta-")
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return info.Metadata.Get("X-Amz-Meta-" + key)
+}
+
+func formatMtime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 9, 64)
+}
+
+func parseMtime(v string) time.Time {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9))
+}
+
+// GetTags returns the tags of an object
+func (c *Client) GetTags(ctx context.Context, key string) (map[string]string, error) {
+	t, err := c.client.GetObjectTagging(ctx, c.bucket, key, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags of %s: %w", key, err)
+	}
+	return t.ToMap(), nil
+}
+
+// SetTags replaces all tags of an object
+func (c *Client) SetTags(ctx context.Context, key string, values map[string]string) error {
+	t, err := tags.NewTags(values, true)
+	if err != nil {
+		return fmt.Errorf("invalid tags: %w", err)
+	}
+	if err := c.client.PutObjectTagging(ctx, c.bucket, key, t, minio.PutObjectTaggingOptions{}); err != nil {
+		return fmt.Errorf("failed to set tags of %s: %w", key, err)
+	}
+	return nil
+}
+
+// RemoveTags removes all tags from an object
+func (c *Client) RemoveTags(ctx context.Context, key string) error {
+	if err := c.client.RemoveObjectTagging(ctx, c.bucket, key, minio.RemoveObjectTaggingOptions{}); err != nil {
+		return fmt.Errorf("failed to remove tags of %s: %w", key, err)
+	}
+	return nil
+}
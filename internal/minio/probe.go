@@ -0,0 +1,177 @@
+package minio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// probePrefix is where the probe writes its scratch object
+const probePrefix = ".minio-drive-probe/"
+
+// Capability is the outcome of one permission check
+type Capability struct {
+	Allowed bool   `json:"allowed"`
+	Tried   bool   `json:"tried"` // false when an earlier step made the check impossible
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ProbeReport describes what the configured credentials can do on the bucket
+type ProbeReport struct {
+	Endpoint     string     `json:"endpoint"`
+	Bucket       string     `json:"bucket"`
+	Reachable    bool       `json:"reachable"`
+	BucketExists bool       `json:"bucket_exists"`
+	List         Capability `json:"list"`
+	Put          Capability `json:"put"`
+	Get          Capability `json:"get"`
+	Delete       Capability `json:"delete"`
+	ScratchKey   string     `json:"scratch_key,omitempty"`
+	CheckedAt    time.Time  `json:"checked_at"`
+	Error        string     `json:"error,omitempty"` // why the bucket could not be checked
+
+	err error // first error seen, for classifying the report
+}
+
+// CheckAccess checks that the server answers, that the bucket exists and
+// that the credentials may list it, without changing anything. Put, Get
+// and Delete are left untried.
+func (c *Client) CheckAccess(ctx context.Context) *ProbeReport {
+	r := &ProbeReport{
+		Endpoint:  c.endpoint,
+		Bucket:    c.bucket,
+		CheckedAt: time.Now(),
+	}
+
+	exists, err := c.client.BucketExists(ctx, c.bucket)
+	if err != nil {
+		r.err = err
+		r.Error = err.Error()
+		r.Reachable = !IsOffline(err)
+		return r
+	}
+	r.Reachable = true
+	r.BucketExists = exists
+	if !exists {
+		return r
+	}
+
+	// List
+	listCtx, cancel := context.WithCancel(ctx)
+	objects := c.client.ListObjects(listCtx, c.bucket, minio.ListObjectsOptions{MaxKeys: 1})
+	r.List = capability(firstListError(objects))
+	cancel()
+
+	return r
+}
+
+// Probe checks that the server answers and that the credentials may list,
+// put, get and delete on the bucket, using a scratch object. It never
+// creates the bucket.
+func (c *Client) Probe(ctx context.Context) *ProbeReport {
+	r := c.CheckAccess(ctx)
+	if !r.BucketExists {
+		return r
+	}
+
+	// Put
+	host, _ := os.Hostname()
+	r.ScratchKey = fmt.Sprintf("%s%s-%d", probePrefix, host, time.Now().UnixNano())
+	payload := []byte("minio-drive permission probe\n")
+	_, err := c.client.PutObject(ctx, c.bucket, r.ScratchKey, bytes.NewReader(payload), int64(len(payload)), minio.PutObjectOptions{})
+	r.Put = capability(err)
+	if err != nil {
+		r.Get.Error = "not tried: put failed"
+		r.Delete.Error = "not tried: put failed"
+		return r
+	}
+
+	// Get
+	obj, err := c.client.GetObject(ctx, c.bucket, r.ScratchKey, minio.GetObjectOptions{})
+	if err == nil {
+		var got []byte
+		got, err = io.ReadAll(obj)
+		obj.Close()
+		if err == nil && !bytes.Equal(got, payload) {
+			err = fmt.Errorf("read back %d bytes, wrote %d", len(got), len(payload))
+		}
+	}
+	r.Get = capability(err)
+
+	// Delete
+	r.Delete = capability(c.client.RemoveObject(ctx, c.bucket, r.ScratchKey, minio.RemoveObjectOptions{}))
+
+	return r
+}
+
+// firstListError returns the error of the first listing result; one page
+// is enough to know whether listing is allowed
+func firstListError(objects <-chan minio.ObjectInfo) error {
+	if obj, ok := <-objects; ok {
+		return obj.Err
+	}
+	return nil
+}
+
+func capability(err error) Capability {
+	if err == nil {
+		return Capability{Allowed: true, Tried: true}
+	}
+	return Capability{Tried: true, Code: ErrorCode(err), Error: err.Error()}
+}
+
+// UploadError explains in actionable terms why uploads cannot work, or
+// returns nil when the bucket accepts new objects
+func (r *ProbeReport) UploadError() error {
+	if err := r.accessError(); err != nil {
+		return err
+	}
+	if !r.Put.Allowed {
+		return fmt.Errorf("your access key may not write to bucket %q (%s); ask the administrator for s3:PutObject on it",
+			r.Bucket, r.Put.Code)
+	}
+	return nil
+}
+
+// MountError explains in actionable terms why the bucket cannot be browsed
+// as a drive, or returns nil when listing and reading work. A drive that
+// can only be read is not an error; check Put.Allowed for that.
+func (r *ProbeReport) MountError() error {
+	if err := r.accessError(); err != nil {
+		return err
+	}
+	if !r.List.Allowed {
+		return fmt.Errorf("your access key may not list bucket %q (%s); ask the administrator for s3:ListBucket on it",
+			r.Bucket, r.List.Code)
+	}
+	if r.Get.Tried && !r.Get.Allowed {
+		return fmt.Errorf("your access key may not read from bucket %q (%s); ask the administrator for s3:GetObject on it",
+			r.Bucket, r.Get.Code)
+	}
+	return nil
+}
+
+// accessError covers the problems shared by uploads and mounts
+func (r *ProbeReport) accessError() error {
+	switch {
+	case !r.Reachable:
+		return fmt.Errorf("cannot reach %s; check minio.endpoint, minio.use_ssl and your network: %w", r.Endpoint, r.err)
+	case r.err != nil:
+		switch ErrorCode(r.err) {
+		case "InvalidAccessKeyId", "SignatureDoesNotMatch":
+			return fmt.Errorf("the server rejected the credentials; check minio.access_key and minio.secret_key: %w", r.err)
+		case "AccessDenied":
+			return fmt.Errorf("your access key may not use bucket %q; ask the administrator for access: %w", r.Bucket, r.err)
+		}
+		return fmt.Errorf("failed to check bucket %q: %w", r.Bucket, r.err)
+	case !r.BucketExists:
+		return fmt.Errorf("%w: %q on %s; create it, or set minio.bootstrap to \"create\"", ErrBucketMissing, r.Bucket, r.Endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package minio
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrStalled is returned when an upload sends no data for the stall timeout
+var ErrStalled = errors.New("upload stalled")
+
+// stallGuard cancels an upload when its progress stops
+type stallGuard struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	timeout time.Duration
+	paused  bool // waiting for the source, which is not the upload's fault
+}
+
+// alive restarts the timeout after bytes were sent
+func (g *stallGuard) alive() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.paused {
+		g.timer.Reset(g.timeout)
+	}
+}
+
+func (g *stallGuard) pause() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = true
+	g.timer.Stop()
+}
+
+func (g *stallGuard) resume() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.paused = false
+	g.timer.Reset(g.timeout)
+}
+
+// guardStall returns a context that is cancelled with ErrStalled when no
+// bytes are sent for o.StallTimeout, and options whose progress callback
+// keeps it alive. stop must be called when the upload returns.
+func guardStall(ctx context.Context, o UploadOptions) (context.Context, UploadOptions, func()) {
+	ctx, o, _, stop := guardStallSource(ctx, o, nil)
+	return ctx, o, stop
+}
+
+// guardStallSource is guardStall for an upload that reads its source as it
+// goes, such as a pipe. The timeout only runs while the upload could be
+// sending: time spent waiting for a slow source does not count.
+func guardStallSource(ctx context.Context, o UploadOptions, r io.Reader) (context.Context, UploadOptions, io.Reader, func()) {
+	if o.StallTimeout <= 0 {
+		return ctx, o, r, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	g := &stallGuard{timeout: o.StallTimeout}
+	g.timer = time.AfterFunc(o.StallTimeout, func() {
+		cancel(fmt.Errorf("%w: nothing sent for %s", ErrStalled, o.StallTimeout))
+	})
+
+	progress := o.Progress
+	o.Progress = func(objectName string, sent int64) {
+		g.alive()
+		if progress != nil {
+			progress(objectName, sent)
+		}
+	}
+
+	if r != nil {
+		r = &sourceReader{r: r, g: g}
+	}
+	return ctx, o, r, func() {
+		g.timer.Stop()
+		cancel(nil)
+	}
+}
+
+// sourceReader pauses a stall guard while the upload waits for its source
+type sourceReader struct {
+	r io.Reader
+	g *stallGuard
+}
+
+func (s *sourceReader) Read(b []byte) (int, error) {
+	s.g.pause()
+	defer s.g.resume()
+	return s.r.Read(b)
+}
+
+// stallError replaces the cancellation error of a stalled upload with the
+// reason it was cancelled
+func stallError(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); errors.Is(cause, ErrStalled) {
+		return cause
+	}
+	return err
+}
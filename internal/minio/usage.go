@@ -0,0 +1,141 @@
+package minio
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// UsageStat holds the totals for one group of objects
+type UsageStat struct {
+	Name    string `json:"name"`
+	Bytes   int64  `json:"bytes"`
+	Objects int64  `json:"objects"`
+}
+
+// UsageReport is the result of walking a bucket or prefix
+type UsageReport struct {
+	Bucket     string      `json:"bucket"`
+	Prefix     string      `json:"prefix"`
+	Bytes      int64       `json:"bytes"`
+	Objects    int64       `json:"objects"`
+	Folders    []UsageStat `json:"folders"`
+	Extensions []UsageStat `json:"extensions"`
+	Ages       []UsageStat `json:"ages"`
+	ScannedAt  time.Time   `json:"scanned_at"`
+}
+
+// ageBucket is an upper bound on object age and the label it is reported under
+type ageBucket struct {
+	name   string
+	maxAge time.Duration
+}
+
+var ageBuckets = []ageBucket{
+	{"< 7 days", 7 * 24 * time.Hour},
+	{"7-30 days", 30 * 24 * time.Hour},
+	{"30-90 days", 90 * 24 * time.Hour},
+	{"90-365 days", 365 * 24 * time.Hour},
+	{"> 1 year", 0},
+}
+
+// rootFolder is the folder name used for objects directly under the prefix
+const rootFolder = "(root)"
+
+// noExtension is the extension name used for objects without one
+const noExtension = "(none)"
+
+// Usage walks all objects under prefix and reports total bytes and object
+// counts, broken down per top-level folder, extension and age
+func (c *Client) Usage(ctx context.Context, prefix string) (*UsageReport, error) {
+	now := time.Now()
+	folders := make(map[string]*UsageStat)
+	extensions := make(map[string]*UsageStat)
+	ages := make([]UsageStat, len(ageBuckets))
+	for i, b := range ageBuckets {
+		ages[i].Name = b.name
+	}
+
+	report := &UsageReport{
+		Bucket:    c.bucket,
+		Prefix:    prefix,
+		ScannedAt: now,
+	}
+
+	objects := c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+
+		report.Bytes += obj.Size
+		report.Objects++
+
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		rel = strings.TrimPrefix(rel, "/")
+		addUsage(folders, topLevelFolder(rel), obj.Size)
+		addUsage(extensions, extensionOf(rel), obj.Size)
+
+		age := now.Sub(obj.LastModified)
+		for i, b := range ageBuckets {
+			if b.maxAge == 0 || age < b.maxAge {
+				ages[i].Bytes += obj.Size
+				ages[i].Objects++
+				break
+			}
+		}
+	}
+
+	report.Folders = sortedUsage(folders)
+	report.Extensions = sortedUsage(extensions)
+	report.Ages = ages
+	return report, nil
+}
+
+func topLevelFolder(rel string) string {
+	if i := strings.Index(rel, "/"); i >= 0 {
+		return rel[:i]
+	}
+	return rootFolder
+}
+
+func extensionOf(rel string) string {
+	ext := strings.ToLower(path.Ext(rel))
+	if ext == "" {
+		return noExtension
+	}
+	return ext
+}
+
+func addUsage(stats map[string]*UsageStat, name string, size int64) {
+	stat, ok := stats[name]
+	if !ok {
+		stat = &UsageStat{Name: name}
+		stats[name] = stat
+	}
+	stat.Bytes += size
+	stat.Objects++
+}
+
+// sortedUsage returns the stats ordered by size, largest first
+func sortedUsage(stats map[string]*UsageStat) []UsageStat {
+	result := make([]UsageStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Bytes != result[j].Bytes {
+			return result[i].Bytes > result[j].Bytes
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,452 @@
+package minio
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"simple-uploader/internal/filter"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// ErrVerifyMismatch is returned when a stored object does not match the local file
+var ErrVerifyMismatch = errors.New("verification mismatch")
+
+// singlePartLimit is the size from which minio-go uploads a file in parts
+// when no part size is set. Only single PUT uploads can carry a
+// whole-object checksum header.
+const singlePartLimit = 16 * 1024 * 1024
+
+// checksumType maps a verify mode from config to a minio checksum type
+func checksumType(mode string) (minio.ChecksumType, error) {
+	switch strings.ToLower(mode) {
+	case "":
+		return minio.ChecksumNone, nil
+	case "crc32c":
+		return minio.ChecksumCRC32C, nil
+	case "sha256":
+		return minio.ChecksumSHA256, nil
+	default:
+		return minio.ChecksumNone, fmt.Errorf("unknown verify mode %q (use crc32c or sha256)", mode)
+	}
+}
+
+// fileChecksum computes a checksum of the given type over the whole file
+func fileChecksum(filePath string, t minio.ChecksumType) (minio.Checksum, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return minio.Checksum{}, err
+	}
+	defer f.Close()
+
+	return t.ChecksumReader(f)
+}
+
+// storedChecksum returns the checksum of the given type reported by the server
+func storedChecksum(info minio.ObjectInfo, t minio.ChecksumType) string {
+	switch t {
+	case minio.ChecksumCRC32C:
+		return info.ChecksumCRC32C
+	case minio.ChecksumSHA256:
+		return info.ChecksumSHA256
+	}
+	return ""
+}
+
+// uploadVerified uploads a file with checksums and confirms the stored
+// object against them. A file sent with a single PUT carries a checksum of
+// the configured type over the whole file. A multipart upload carries a
+// CRC32C of every part, and the object's CRC32C of those is compared with
+// one computed locally, whatever the verify mode.
+func (c *Client) uploadVerified(ctx context.Context, o UploadOptions, filePath, objectName string) (UploadInfo, error) {
+	t, err := checksumType(o.Verify)
+	if err != nil {
+		return UploadInfo{}, err
+	}
+
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, err)
+	}
+
+	if fi.Size() >= singlePartLimit || (o.PartSize > 0 && uint64(fi.Size()) >= o.PartSize) {
+		return c.uploadVerifiedParts(ctx, o, filePath, objectName, fi.Size())
+	}
+
+	sum, err := fileChecksum(filePath, t)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to checksum %s: %w", filePath, err)
+	}
+
+	// The checksum can take a while on large files; only time the transfer
+	ctx, o, stop := guardStall(ctx, o)
+	defer stop()
+
+	opts := putOptions(o, objectName)
+	if opts.UserMetadata == nil {
+		opts.UserMetadata = make(map[string]string, 1)
+	}
+	opts.UserMetadata[t.Key()] = sum.Encoded()
+
+	info, err := c.client.FPutObject(ctx, c.bucket, objectName, filePath, opts)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, stallError(ctx, err))
+	}
+
+	stat, err := c.client.StatObject(ctx, c.bucket, objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to verify %s: %w", filePath, err)
+	}
+	if err := checkChecksum(stat, filePath, fi.Size(), t, sum.Encoded()); err != nil {
+		return UploadInfo{}, err
+	}
+	return uploadInfo(info), nil
+}
+
+// uploadVerifiedParts uploads a file as a multipart upload with a CRC32C
+// of every part and confirms the stored object against the part checksums
+// of the local file
+func (c *Client) uploadVerifiedParts(ctx context.Context, o UploadOptions, filePath, objectName string, size int64) (UploadInfo, error) {
+	_, partSize, _, err := minio.OptimalPartInfo(size, o.PartSize)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, err)
+	}
+
+	sum, err := filePartChecksum(filePath, partSize)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to checksum %s: %w", filePath, err)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, err)
+	}
+	defer f.Close()
+
+	ctx, o, stop := guardStall(ctx, o)
+	defer stop()
+
+	// minio-go only sends part checksums for readers it cannot read at an
+	// offset, so the file is passed as a plain reader
+	opts := putOptions(o, objectName)
+	opts.PartSize = uint64(partSize)
+	info, err := c.client.PutObject(ctx, c.bucket, objectName, struct{ io.Reader }{f}, size, opts)
+	if err != nil {
+		return UploadInfo{}, fmt.Errorf("failed to upload %s: %w", filePath, stallError(ctx, err))
+	}
+
+	if err := c.verifyParts(ctx, filePath, objectName, size, sum); err != nil {
+		return UploadInfo{}, err
+	}
+	return uploadInfo(info), nil
+}
+
+// verifyParts confirms the size and the part checksums of an object after
+// a multipart upload
+func (c *Client) verifyParts(ctx context.Context, source, objectName string, size int64, want string) error {
+	stat, err := c.client.StatObject(ctx, c.bucket, objectName, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %w", source, err)
+	}
+	return checkChecksum(stat, source, size, minio.ChecksumCRC32C, want)
+}
+
+// checkChecksum compares the size and the stored checksum of an object
+// with the values computed locally. A composite checksum may be reported
+// with or without its part count.
+func checkChecksum(stat minio.ObjectInfo, source string, size int64, t minio.ChecksumType, want string) error {
+	if stat.Size != size {
+		return fmt.Errorf("%s: %w: size is %d, expected %d", source, ErrVerifyMismatch, stat.Size, size)
+	}
+	stored := storedChecksum(stat, t)
+	base, _, _ := strings.Cut(want, "-")
+	switch stored {
+	case want, base:
+		return nil
+	case "":
+		return fmt.Errorf("%s: %w: the server stored no %s checksum", source, ErrVerifyMismatch, t)
+	}
+	return fmt.Errorf("%s: %w: %s is %s, expected %s", source, ErrVerifyMismatch, t, stored, want)
+}
+
+// partChecksum computes the CRC32C of every part of a multipart upload
+// and, from those, the checksum S3 stores for the object
+type partChecksum struct {
+	partSize int64
+	part     hash.Hash
+	inPart   int64
+	sums     []byte
+	parts    int
+}
+
+func newPartChecksum(partSize int64) *partChecksum {
+	return &partChecksum{partSize: partSize, part: minio.ChecksumCRC32C.Hasher()}
+}
+
+func (p *partChecksum) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		chunk := b
+		if room := p.partSize - p.inPart; int64(len(chunk)) > room {
+			chunk = chunk[:room]
+		}
+		p.part.Write(chunk)
+		p.inPart += int64(len(chunk))
+		b = b[len(chunk):]
+		if p.inPart == p.partSize {
+			p.endPart()
+		}
+	}
+	return n, nil
+}
+
+func (p *partChecksum) endPart() {
+	p.sums = p.part.Sum(p.sums)
+	p.parts++
+	p.part.Reset()
+	p.inPart = 0
+}
+
+// Encoded ends the last part and returns the object checksum as S3
+// reports it: the base64 checksum of the part checksums and the part count
+func (p *partChecksum) Encoded() string {
+	if p.inPart > 0 || p.parts == 0 {
+		p.endPart()
+	}
+	return fmt.Sprintf("%s-%d", minio.ChecksumCRC32C.ChecksumBytes(p.sums).Encoded(), p.parts)
+}
+
+// Mismatch describes a file whose stored object differs from the local copy
+type Mismatch struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// VerifyReport is the result of comparing a local folder against a prefix
+type VerifyReport struct {
+	Matched    int        `json:"matched"`
+	Missing    []string   `json:"missing"`
+	Extra      []string   `json:"extra"`
+	Mismatched []Mismatch `json:"mismatched"`
+}
+
+// OK reports whether the local folder and the prefix are identical
+func (r *VerifyReport) OK() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Mismatched) == 0
+}
+
+// VerifyTree compares the files under localDir with the objects under prefix.
+// Sizes are always compared; content is compared against the stored
+// checksum when there is one, otherwise against the ETag of an unencrypted
+// single PUT object, which is its MD5.
+// Paths excluded by filters or .minioignore files are left out on both sides.
+func (c *Client) VerifyTree(ctx context.Context, localDir, prefix string, filters []string) (*VerifyReport, error) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	ignore := filter.New(localDir, filters)
+
+	remote := make(map[string]minio.ObjectInfo)
+	objects := c.client.ListObjects(ctx, c.bucket, minio.ListObjectsOptions{
+		Prefix:    prefix,
+		Recursive: true,
+	})
+	for obj := range objects {
+		if obj.Err != nil {
+			return nil, fmt.Errorf("failed to list objects: %w", obj.Err)
+		}
+		if strings.HasSuffix(obj.Key, "/") {
+			continue // folder marker
+		}
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if ignore.Excluded(rel, false) {
+			continue
+		}
+		remote[rel] = obj
+	}
+
+	report := &VerifyReport{}
+	err := filepath.WalkDir(localDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(localDir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if rel != "." && ignore.Excluded(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		obj, ok := remote[rel]
+		if !ok {
+			report.Missing = append(report.Missing, rel)
+			return nil
+		}
+		delete(remote, rel)
+
+		reason, err := c.compareObject(ctx, p, obj)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			report.Mismatched = append(report.Mismatched, Mismatch{Path: rel, Reason: reason})
+		} else {
+			report.Matched++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for rel := range remote {
+		report.Extra = append(report.Extra, rel)
+	}
+	sort.Strings(report.Extra)
+
+	return report, nil
+}
+
+// compareObject returns a reason when the local file differs from the object
+func (c *Client) compareObject(ctx context.Context, filePath string, obj minio.ObjectInfo) (string, error) {
+	fi, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+	if fi.Size() != obj.Size {
+		return fmt.Sprintf("size %d, stored %d", fi.Size(), obj.Size), nil
+	}
+
+	stat, err := c.client.StatObject(ctx, c.bucket, obj.Key, minio.StatObjectOptions{Checksum: true})
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %w", obj.Key, err)
+	}
+
+	// A stored whole-object checksum is the best evidence
+	for _, t := range []minio.ChecksumType{minio.ChecksumSHA256, minio.ChecksumCRC32C} {
+		stored := storedChecksum(stat, t)
+		if stored == "" || strings.Contains(stored, "-") {
+			continue
+		}
+		sum, err := fileChecksum(filePath, t)
+		if err != nil {
+			return "", err
+		}
+		if sum.Encoded() != stored {
+			return fmt.Sprintf("%s %s, stored %s", t, sum.Encoded(), stored), nil
+		}
+		return "", nil
+	}
+
+	// The part checksums can be recomputed when the parts were cut the way
+	// this client cuts them
+	if stored := stat.ChecksumCRC32C; strings.Contains(stored, "-") {
+		_, count, _ := strings.Cut(stored, "-")
+		for _, partSize := range c.partSizes(fi.Size()) {
+			parts := (fi.Size() + partSize - 1) / partSize
+			if count != strconv.FormatInt(max(parts, 1), 10) {
+				continue
+			}
+			sum, err := filePartChecksum(filePath, partSize)
+			if err != nil {
+				return "", err
+			}
+			if sum != stored {
+				return fmt.Sprintf("crc32c %s, stored %s", sum, stored), nil
+			}
+			return "", nil
+		}
+	}
+
+	// Otherwise the ETag is the MD5 of the content, but only for a single
+	// PUT without server-side encryption
+	etag := strings.Trim(stat.ETag, `"`)
+	if len(etag) == 32 && !strings.Contains(etag, "-") && !encrypted(stat) {
+		sum, err := FileMD5(filePath)
+		if err != nil {
+			return "", err
+		}
+		if sum != etag {
+			return fmt.Sprintf("md5 %s, stored %s", sum, etag), nil
+		}
+	}
+	return "", nil
+}
+
+// partSizes returns the part sizes this client may have split an object
+// of the given size into: as a file, and as a stream
+func (c *Client) partSizes(size int64) []int64 {
+	var sizes []int64
+	if _, partSize, _, err := minio.OptimalPartInfo(size, c.opts.PartSize); err == nil {
+		sizes = append(sizes, partSize)
+	}
+	if c.opts.PartSize == 0 {
+		sizes = append(sizes, defaultStreamPartSize)
+	}
+	return sizes
+}
+
+// filePartChecksum computes the part checksum of a file split into parts
+// of the given size
+func filePartChecksum(filePath string, partSize int64) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sum := newPartChecksum(partSize)
+	if _, err := io.Copy(sum, f); err != nil {
+		return "", err
+	}
+	return sum.Encoded(), nil
+}
+
+// encrypted reports whether an object is stored with server-side
+// encryption (SSE-S3, SSE-KMS or SSE-C), whose ETag is not an MD5 of the
+// content
+func encrypted(info minio.ObjectInfo) bool {
+	for k := range info.Metadata {
+		if strings.HasPrefix(http.CanonicalHeaderKey(k), "X-Amz-Server-Side-Encryption") {
+			return true
+		}
+	}
+	return false
+}
+
+// FileMD5 returns the hex MD5 of a file, as found in single-part ETags
+func FileMD5(filePath string) (string, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,168 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"simple-uploader/internal/config"
+
+	"github.com/dustin/go-humanize"
+)
+
+// ErrRejected is wrapped by every policy violation
+var ErrRejected = errors.New("rejected by upload policy")
+
+// Violation is the reason a file may not be uploaded
+type Violation struct {
+	Reason string
+}
+
+func (v *Violation) Error() string { return v.Reason }
+
+func (v *Violation) Unwrap() error { return ErrRejected }
+
+// Policy holds the upload guardrails set by storage admins
+type Policy struct {
+	maxFileSize  uint64
+	maxBatchSize uint64
+	allowed      map[string]bool
+	denied       map[string]bool
+	forbidden    []string
+}
+
+// File is a candidate for upload. Size is -1 when unknown, as for stdin.
+type File struct {
+	Path string // local path, reported back in rejections
+	Name string // name the extension and name rules apply to
+	Size int64
+}
+
+// Rejection pairs a file with the reason it was refused
+type Rejection struct {
+	Path string
+	Err  *Violation
+}
+
+// New checks the configured policy and prepares it for use
+func New(c *config.PolicyConfig) (*Policy, error) {
+	p := &Policy{
+		maxFileSize:  c.MaxFileSize,
+		maxBatchSize: c.MaxBatchSize,
+		allowed:      extensionSet(c.AllowedExtensions),
+		denied:       extensionSet(c.DeniedExtensions),
+	}
+	for _, pattern := range c.ForbiddenNames {
+		pattern = strings.ToLower(pattern)
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid forbidden name pattern %q: %w", pattern, err)
+		}
+		p.forbidden = append(p.forbidden, pattern)
+	}
+	return p, nil
+}
+
+// CheckFile returns a violation when a single file breaks the policy
+func (p *Policy) CheckFile(name string, size int64) *Violation {
+	base := strings.ToLower(filepath.Base(name))
+
+	for _, pattern := range p.forbidden {
+		if ok, _ := filepath.Match(pattern, base); ok {
+			return &Violation{Reason: fmt.Sprintf("file name matches forbidden pattern %q", pattern)}
+		}
+	}
+
+	ext := strings.ToLower(filepath.Ext(base))
+	if p.denied[ext] {
+		return &Violation{Reason: fmt.Sprintf("%s files are not allowed", displayExt(ext))}
+	}
+	if len(p.allowed) > 0 && !p.allowed[ext] {
+		return &Violation{Reason: fmt.Sprintf("%s files are not in the allowed list", displayExt(ext))}
+	}
+
+	if p.maxFileSize > 0 && size > 0 && uint64(size) > p.maxFileSize {
+		return &Violation{Reason: fmt.Sprintf("file is %s, larger than the %s limit",
+			humanize.IBytes(uint64(size)), humanize.IBytes(p.maxFileSize))}
+	}
+	return nil
+}
+
+// Check applies the policy to a batch and returns the files that may be
+// uploaded along with every rejection. When the accepted files together
+// exceed the batch limit, all of them are rejected, since no subset is
+// more right to send than another.
+func (p *Policy) Check(files []File) ([]File, []Rejection) {
+	var accepted []File
+	var rejected []Rejection
+	var total uint64
+
+	for _, f := range files {
+		if v := p.CheckFile(f.Name, f.Size); v != nil {
+			rejected = append(rejected, Rejection{Path: f.Path, Err: v})
+			continue
+		}
+		accepted = append(accepted, f)
+		if f.Size > 0 {
+			total += uint64(f.Size)
+		}
+	}
+
+	if p.maxBatchSize > 0 && total > p.maxBatchSize {
+		v := &Violation{Reason: fmt.Sprintf("batch is %s, larger than the %s limit",
+			humanize.IBytes(total), humanize.IBytes(p.maxBatchSize))}
+		for _, f := range accepted {
+			rejected = append(rejected, Rejection{Path: f.Path, Err: v})
+		}
+		return nil, rejected
+	}
+	return accepted, rejected
+}
+
+// LimitReader enforces the file size limit on a stream whose size is not
+// known up front. Reading fails once the limit is passed.
+func (p *Policy) LimitReader(r io.Reader) io.Reader {
+	if p.maxFileSize == 0 {
+		return r
+	}
+	return &limitReader{r: r, max: p.maxFileSize}
+}
+
+type limitReader struct {
+	r    io.Reader
+	max  uint64
+	read uint64
+}
+
+func (l *limitReader) Read(b []byte) (int, error) {
+	n, err := l.r.Read(b)
+	l.read += uint64(n)
+	if l.read > l.max {
+		return n, &Violation{Reason: fmt.Sprintf("stream is larger than the %s limit", humanize.IBytes(l.max))}
+	}
+	return n, err
+}
+
+// extensionSet normalizes "PDF", ".pdf" and "*.pdf" to ".pdf"
+func extensionSet(exts []string) map[string]bool {
+	set := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "*"))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
+
+func displayExt(ext string) string {
+	if ext == "" {
+		return "extensionless"
+	}
+	return ext
+}
@@ -0,0 +1,159 @@
+package queue
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"simple-uploader/internal/minio"
+)
+
+// Drainer retries queued uploads in the background
+type Drainer struct {
+	Queue *Queue
+
+	// Upload sends one item; a nil error removes it from the queue
+	Upload func(ctx context.Context, item Item) error
+
+	// Reachable reports whether the endpoint for a profile can be reached.
+	// Items are not attempted while it returns false.
+	Reachable func(ctx context.Context, profile string) bool
+
+	// OnUploaded is called after a queued item finally uploads, unless it
+	// was cancelled meanwhile
+	OnUploaded func(item Item)
+
+	// OnGiveUp is called once when an item reaches MaxAttempts, or right
+	// away when the server or the upload policy refuses it
+	OnGiveUp func(item Item)
+
+	Interval    time.Duration // how often the queue is checked
+	MaxAttempts int           // 0 retries forever
+
+	mu sync.Mutex // serializes passes so an item is never sent twice
+}
+
+const (
+	minBackoff = 30 * time.Second
+	maxBackoff = 30 * time.Minute
+
+	// cancelCheck is how often an upload checks whether its item was cancelled
+	cancelCheck = 2 * time.Second
+)
+
+// Run drains the queue until ctx is cancelled
+func (d *Drainer) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+
+	for {
+		d.Drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Drain makes one pass over the queue, uploading items that are due
+func (d *Drainer) Drain(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	items, err := d.Queue.List()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	reachable := make(map[string]bool)
+	for _, item := range items {
+		if ctx.Err() != nil {
+			return
+		}
+		if item.GivenUp || d.MaxAttempts > 0 && item.Attempts >= d.MaxAttempts {
+			continue
+		}
+		if now.Before(item.NextAttempt) {
+			continue
+		}
+
+		// Check each profile's endpoint once per pass
+		ok, checked := reachable[item.Profile]
+		if !checked {
+			ok = d.Reachable == nil || d.Reachable(ctx, item.Profile)
+			reachable[item.Profile] = ok
+		}
+		if !ok {
+			continue
+		}
+
+		err := d.upload(ctx, item)
+		if !d.Queue.Has(item.ID) {
+			d.Queue.RemoveCopy(item.ID) // cancelled while uploading
+			continue
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return // shutting down; the attempt does not count
+			}
+			item.Attempts++
+			item.LastError = err.Error()
+			item.NextAttempt = time.Now().Add(backoff(item.Attempts))
+			item.GivenUp = minio.IsPermanent(err)
+			_ = d.Queue.Update(item)
+
+			giveUp := item.GivenUp || d.MaxAttempts > 0 && item.Attempts >= d.MaxAttempts
+			if giveUp && d.OnGiveUp != nil {
+				d.OnGiveUp(item)
+			}
+			continue
+		}
+
+		if err := d.Queue.Remove(item.ID); err != nil {
+			continue // cancelled as the upload finished
+		}
+		if d.OnUploaded != nil {
+			d.OnUploaded(item)
+		}
+	}
+}
+
+// upload sends one item, aborting when the item is cancelled meanwhile,
+// for example with minioctl queue cancel
+func (d *Drainer) upload(ctx context.Context, item Item) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		ticker := time.NewTicker(cancelCheck)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if !d.Queue.Has(item.ID) {
+					cancel()
+					return
+				}
+			}
+		}
+	}()
+
+	return d.Upload(ctx, item)
+}
+
+// backoff doubles the wait after each failed attempt, up to maxBackoff
+func backoff(attempts int) time.Duration {
+	wait := minBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
@@ -0,0 +1,57 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simple-uploader/internal/policy"
+)
+
+func TestDrainGivesUpOnRefusal(t *testing.T) {
+	q, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := filepath.Join(t.TempDir(), "setup.exe")
+	if err := os.WriteFile(src, []byte("MZ"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Add(Item{Path: src, Key: "setup.exe"}); err != nil {
+		t.Fatal(err)
+	}
+
+	uploads, givenUp := 0, 0
+	d := &Drainer{
+		Queue: q,
+		Upload: func(ctx context.Context, item Item) error {
+			uploads++
+			return fmt.Errorf("failed to upload: %w", &policy.Violation{Reason: ".exe files are not allowed"})
+		},
+		OnGiveUp: func(item Item) { givenUp++ },
+	}
+
+	d.Drain(context.Background())
+	items, err := q.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || !items[0].GivenUp {
+		t.Fatalf("items = %+v, want one given up", items)
+	}
+	if givenUp != 1 {
+		t.Errorf("OnGiveUp called %d times, want 1", givenUp)
+	}
+
+	// Given up items are not sent again, even once the backoff is over
+	items[0].NextAttempt = items[0].QueuedAt
+	if err := q.Update(items[0]); err != nil {
+		t.Fatal(err)
+	}
+	d.Drain(context.Background())
+	if uploads != 1 {
+		t.Errorf("upload attempted %d times, want 1", uploads)
+	}
+}
@@ -0,0 +1,232 @@
+package queue
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/config"
+)
+
+// Item is one queued upload
+type Item struct {
+	ID           string            `json:"id"`
+	Path         string            `json:"path"`           // the file that was to be uploaded
+	Copy         string            `json:"copy,omitempty"` // its content as queued, which is what is sent
+	Key          string            `json:"key"`
+	Profile      string            `json:"profile,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	Tags         map[string]string `json:"tags,omitempty"`
+	StorageClass string            `json:"storage_class,omitempty"`
+	Size         int64             `json:"size"`
+	QueuedAt     time.Time         `json:"queued_at"`
+	Attempts     int               `json:"attempts"`
+	LastError    string            `json:"last_error,omitempty"`
+	NextAttempt  time.Time         `json:"next_attempt"`
+	GivenUp      bool              `json:"given_up,omitempty"` // refused for good; only retried on request
+}
+
+// Queue stores pending uploads as one JSON file per item in a directory,
+// so separate uploader processes can add items while the mounter drains them
+type Queue struct {
+	dir string
+}
+
+const (
+	itemExt = ".json"
+	copyExt = ".data"
+)
+
+// OpenFromConfig opens the queue directory configured in the queue section
+func OpenFromConfig(cfg *config.QueueConfig) (*Queue, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return Open(dir)
+}
+
+// DefaultDir returns the queue directory under the user config dir
+func DefaultDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "MinIODrive", "queue"), nil
+}
+
+// Open opens the queue in dir, creating the directory if needed
+func Open(dir string) (*Queue, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create queue directory: %w", err)
+	}
+	return &Queue{dir: dir}, nil
+}
+
+// Dir returns the queue directory
+func (q *Queue) Dir() string {
+	return q.dir
+}
+
+// Add stores a new item with a copy of the file at item.Path, so what is
+// uploaded later is the content as it was when queued, and returns it with
+// its ID, copy and timestamps set
+func (q *Queue) Add(item Item) (Item, error) {
+	id, err := newID()
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.ID = id
+	item.Copy = filepath.Join(q.dir, id+copyExt)
+	if item.Size, err = copyFile(item.Path, item.Copy); err != nil {
+		return Item{}, fmt.Errorf("failed to copy %s into the queue: %w", item.Path, err)
+	}
+
+	item.QueuedAt = time.Now()
+	item.NextAttempt = item.QueuedAt
+	if err := q.Update(item); err != nil {
+		_ = os.Remove(item.Copy)
+		return Item{}, err
+	}
+	return item, nil
+}
+
+// Source returns the file to upload for an item. Items queued before
+// copies were kept have none and are sent from their original path.
+func (i Item) Source() string {
+	if i.Copy != "" {
+		return i.Copy
+	}
+	return i.Path
+}
+
+// copyFile copies src to dst through a temp file and returns its size
+func copyFile(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return 0, err
+	}
+	return n, nil
+}
+
+// Update writes an existing item back to the queue
+func (q *Queue) Update(item Item) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file first so a reader never sees a partial item
+	path := q.itemPath(item.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write queue item: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write queue item: %w", err)
+	}
+	return nil
+}
+
+// List returns all queued items, oldest first
+func (q *Queue) List() ([]Item, error) {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read queue: %w", err)
+	}
+
+	var items []Item
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), itemExt) {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(q.dir, e.Name()))
+		if err != nil {
+			continue // removed while listing
+		}
+
+		var item Item
+		if err := json.Unmarshal(data, &item); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].QueuedAt.Before(items[j].QueuedAt)
+	})
+	return items, nil
+}
+
+// Remove deletes an item and its copy, for example after it uploaded or
+// was cancelled
+func (q *Queue) Remove(id string) error {
+	err := os.Remove(q.itemPath(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("queue item %s not found", id)
+	}
+	if err != nil {
+		return err
+	}
+	q.RemoveCopy(id)
+	return nil
+}
+
+// RemoveCopy deletes the copy of an item. It may fail while the copy is
+// being uploaded, in which case the drainer removes it afterwards.
+func (q *Queue) RemoveCopy(id string) {
+	_ = os.Remove(filepath.Join(q.dir, id+copyExt))
+}
+
+// Has reports whether an item is still queued
+func (q *Queue) Has(id string) bool {
+	_, err := os.Stat(q.itemPath(id))
+	return err == nil
+}
+
+func (q *Queue) itemPath(id string) string {
+	return filepath.Join(q.dir, id+itemExt)
+}
+
+func newID() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102-150405"), hex.EncodeToString(b)), nil
+}
@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"simple-uploader/internal/config"
+	"simple-uploader/internal/filter"
 	"strings"
 	"syscall"
 )
@@ -13,11 +14,13 @@ import (
 const remoteName = "minio"
 
 type Manager struct {
-	rclonePath string
-	configPath string
-	cfg        *config.Config
-	serveCmd   *exec.Cmd  // WebDAV server process
-	mountCmd   *exec.Cmd  // WinFsp mount process
+	rclonePath    string
+	configPath    string
+	awsConfigPath string   // AWS config running the credential process, when there is one
+	secretEnv     []string // secret key for rclone, kept out of rclone.conf
+	cfg           *config.Config
+	serveCmd      *exec.Cmd // WebDAV server process
+	mountCmd      *exec.Cmd // WinFsp mount process
 }
 
 // NewManager creates a new rclone manager
@@ -39,40 +42,63 @@ func NewManager(cfg *config.Config) (*Manager, error) {
 	configPath := filepath.Join(exeDir, "rclone.conf")
 
 	return &Manager{
-		rclonePath: rclonePath,
-		configPath: configPath,
-		cfg:        cfg,
+		rclonePath:    rclonePath,
+		configPath:    configPath,
+		awsConfigPath: filepath.Join(exeDir, "rclone-aws.conf"),
+		cfg:           cfg,
 	}, nil
 }
 
-// GenerateConfig creates rclone.conf for MinIO
+// GenerateConfig creates rclone.conf for MinIO. The secret key is passed
+// to rclone in its environment rather than written to the file. With a
+// credential process rclone runs the process itself, see
+// RenderProcessRemote.
 func (m *Manager) GenerateConfig() error {
-	protocol := "http"
-	if m.cfg.MinIO.UseSSL {
-		protocol = "https"
-	}
-
-	// Ensure endpoint doesn't have protocol prefix
-	endpoint := m.cfg.MinIO.Endpoint
-	endpoint = strings.TrimPrefix(endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
-
-	configContent := fmt.Sprintf(`[%s]
-type = s3
-provider = Minio
-access_key_id = %s
-secret_access_key = %s
-endpoint = %s://%s
-force_path_style = true
-`,
-		remoteName,
-		m.cfg.MinIO.AccessKey,
-		m.cfg.MinIO.SecretKey,
-		protocol,
-		endpoint,
-	)
-
-	return os.WriteFile(m.configPath, []byte(configContent), 0600)
+	m.secretEnv = nil
+	if m.cfg.MinIO.CredentialProcess != "" {
+		content, awsConfig := RenderProcessRemote(remoteName, &m.cfg.MinIO)
+		if err := os.WriteFile(m.awsConfigPath, []byte(awsConfig), 0600); err != nil {
+			return err
+		}
+		return os.WriteFile(m.configPath, []byte(content), 0600)
+	}
+
+	content, env, _, err := RenderConfig(&m.cfg.MinIO)
+	if err != nil {
+		return err
+	}
+	m.secretEnv = env
+	return os.WriteFile(m.configPath, []byte(content), 0600)
+}
+
+// command returns an rclone command with a hidden window. With a
+// credential process, rclone is pointed at the AWS config running it. AWS
+// keys in the environment are dropped and the shared credentials file is
+// pointed at a file that does not exist, so the user's own AWS settings
+// cannot take precedence.
+func (m *Manager) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(m.rclonePath, args...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+
+	if m.cfg.MinIO.CredentialProcess != "" {
+		for _, kv := range os.Environ() {
+			name, _, _ := strings.Cut(kv, "=")
+			switch strings.ToUpper(name) {
+			case "AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+				"AWS_CONFIG_FILE", "AWS_SHARED_CREDENTIALS_FILE":
+				continue
+			}
+			cmd.Env = append(cmd.Env, kv)
+		}
+		cmd.Env = append(cmd.Env,
+			"AWS_CONFIG_FILE="+m.awsConfigPath,
+			"AWS_SHARED_CREDENTIALS_FILE="+m.awsConfigPath+".credentials",
+			"AWS_SDK_LOAD_CONFIG=1",
+		)
+	} else if len(m.secretEnv) > 0 {
+		cmd.Env = append(os.Environ(), m.secretEnv...)
+	}
+	return cmd
 }
 
 // KillExistingProcesses kills any existing rclone processes
@@ -101,17 +127,13 @@ func (m *Manager) StartWebDAV() error {
 	addr := fmt.Sprintf("localhost:%d", m.cfg.Mount.Port)
 
 	// Build serve webdav command
-	m.serveCmd = exec.Command(m.rclonePath,
+	args := []string{
 		"serve", "webdav",
 		"--config", m.configPath,
 		"--addr", addr,
-		remotePath,
-	)
-
-	// Hide console window on Windows
-	m.serveCmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
 	}
+	args = append(args, filter.RcloneArgs(m.cfg.Filters)...)
+	m.serveCmd = m.command(append(args, remotePath)...)
 
 	if err := m.serveCmd.Start(); err != nil {
 		return fmt.Errorf("failed to start WebDAV server: %w", err)
@@ -138,18 +160,13 @@ func (m *Manager) MountWinFsp() error {
 	driveLetter := m.GetDriveLetter()
 
 	// Build mount command
-	m.mountCmd = exec.Command(m.rclonePath,
+	args := []string{
 		"mount",
 		"--config", m.configPath,
 		"--vfs-cache-mode", "full",
-		remotePath,
-		driveLetter,
-	)
-
-	// Hide console window on Windows
-	m.mountCmd.SysProcAttr = &syscall.SysProcAttr{
-		HideWindow: true,
 	}
+	args = append(args, filter.RcloneArgs(m.cfg.Filters)...)
+	m.mountCmd = m.command(append(args, remotePath, driveLetter)...)
 
 	if err := m.mountCmd.Start(); err != nil {
 		return fmt.Errorf("failed to mount drive: %w", err)
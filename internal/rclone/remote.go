@@ -0,0 +1,258 @@
+package rclone
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/secret"
+)
+
+// RenderConfig returns the rclone.conf content for a MinIO section, the
+// environment variables holding its secret key and session token, and when
+// the keys expire if they come from a credential process. The secrets are
+// kept out of the file, which sits next to the executable; rclone reads
+// them from RCLONE_CONFIG_<REMOTE>_<OPTION>.
+func RenderConfig(cfg *config.MinIOConfig) (string, []string, time.Time, error) {
+	accessKey, secretKey, sessionToken, expires, err := remoteKeys(cfg)
+	if err != nil {
+		return "", nil, time.Time{}, err
+	}
+
+	env := []string{configEnv("secret_access_key") + "=" + secretKey}
+	if sessionToken != "" {
+		env = append(env, configEnv("session_token")+"="+sessionToken)
+	}
+	return remoteSection(remoteName, cfg, accessKey, "", ""), env, expires, nil
+}
+
+// configEnv names the environment variable rclone reads an option of the
+// remote from
+func configEnv(option string) string {
+	return strings.ToUpper("RCLONE_CONFIG_" + remoteName + "_" + option)
+}
+
+// RenderRemote returns an rclone remote section with the given name for a
+// MinIO section, and when its keys expire if they come from a credential
+// process. The section holds the secret key, for use in another tool's
+// config.
+func RenderRemote(name string, cfg *config.MinIOConfig) (string, time.Time, error) {
+	accessKey, secretKey, sessionToken, expires, err := remoteKeys(cfg)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return remoteSection(name, cfg, accessKey, secretKey, sessionToken), expires, nil
+}
+
+// remoteKeys returns the keys of a MinIO section, running its credential
+// process if it has one
+func remoteKeys(cfg *config.MinIOConfig) (accessKey, secretKey, sessionToken string, expires time.Time, err error) {
+	if cfg.CredentialProcess == "" {
+		return cfg.AccessKey, cfg.SecretKey, "", time.Time{}, nil
+	}
+	creds, err := secret.ProcessFor(cfg.CredentialProcess).Get(context.Background())
+	if err != nil {
+		return "", "", "", time.Time{}, err
+	}
+	return creds.AccessKeyID, creds.SecretAccessKey, creds.SessionToken, creds.Expiration, nil
+}
+
+// remoteSection renders an s3 remote; an empty secret key or session token
+// is left out
+func remoteSection(name string, cfg *config.MinIOConfig, accessKey, secretKey, sessionToken string) string {
+	protocol := "http"
+	if cfg.UseSSL {
+		protocol = "https"
+	}
+
+	// Ensure endpoint doesn't have protocol prefix
+	endpoint := cfg.Endpoint
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+
+	content := fmt.Sprintf(`[%s]
+type = s3
+provider = Minio
+access_key_id = %s
+`,
+		name,
+		accessKey,
+	)
+	if secretKey != "" {
+		content += fmt.Sprintf("secret_access_key = %s\n", secretKey)
+	}
+	content += fmt.Sprintf("endpoint = %s://%s\nforce_path_style = true\n", protocol, endpoint)
+	if sessionToken != "" {
+		content += fmt.Sprintf("session_token = %s\n", sessionToken)
+	}
+	return content
+}
+
+// awsProfile names the profile in the AWS config file that runs the
+// credential process for rclone
+const awsProfile = "minio-drive"
+
+// RenderProcessRemote returns an rclone remote section for a MinIO section
+// with a credential process, and the AWS config file it reads its keys
+// from. rclone's AWS SDK runs the process itself and runs it again before
+// the keys expire, so a running mount or server picks up new keys without
+// a restart.
+func RenderProcessRemote(name string, cfg *config.MinIOConfig) (remote, awsConfig string) {
+	protocol := "http"
+	if cfg.UseSSL {
+		protocol = "https"
+	}
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(cfg.Endpoint, "http://"), "https://")
+
+	remote = fmt.Sprintf(`[%s]
+type = s3
+provider = Minio
+env_auth = true
+profile = %s
+endpoint = %s://%s
+force_path_style = true
+`,
+		name,
+		awsProfile,
+		protocol,
+		endpoint,
+	)
+	awsConfig = fmt.Sprintf("[profile %s]\ncredential_process = %s\n", awsProfile, cfg.CredentialProcess)
+	return remote, awsConfig
+}
+
+// UserConfigPath returns the rclone.conf rclone itself uses, honoring
+// RCLONE_CONFIG
+func UserConfigPath() (string, error) {
+	if p := os.Getenv("RCLONE_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "rclone", "rclone.conf"), nil
+}
+
+// parseSections reads an rclone.conf into its sections
+func parseSections(data []byte) (map[string]map[string]string, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("RCLONE_ENCRYPT_")) {
+		return nil, fmt.Errorf("encrypted rclone.conf is not supported; decrypt it with \"rclone config encryption remove\"")
+	}
+
+	sections := map[string]map[string]string{}
+	var current map[string]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = map[string]string{}
+			sections[strings.TrimSpace(line[1:len(line)-1])] = current
+		case current != nil:
+			if k, v, ok := strings.Cut(line, "="); ok {
+				current[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+	}
+	return sections, scanner.Err()
+}
+
+// ParseRemote reads an S3 remote from an rclone.conf into a MinIO section.
+// With an empty name the file must hold exactly one S3 remote. rclone
+// remotes carry no bucket, so Bucket is left empty.
+func ParseRemote(data []byte, name string) (*config.MinIOConfig, string, error) {
+	sections, err := parseSections(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if name == "" {
+		var s3 []string
+		for n, s := range sections {
+			if s["type"] == "s3" {
+				s3 = append(s3, n)
+			}
+		}
+		sort.Strings(s3)
+		if len(s3) != 1 {
+			return nil, "", fmt.Errorf("choose a remote with -name; S3 remotes in the file: %s", listOrNone(s3))
+		}
+		name = s3[0]
+	}
+
+	s, ok := sections[name]
+	if !ok {
+		return nil, "", fmt.Errorf("remote %q not found in rclone.conf", name)
+	}
+	if s["type"] != "s3" {
+		return nil, "", fmt.Errorf("remote %q is of type %q, only s3 remotes can be imported", name, s["type"])
+	}
+	if s["env_auth"] == "true" && s["access_key_id"] == "" {
+		return nil, "", fmt.Errorf("remote %q takes its keys from the environment (env_auth), there are none to import", name)
+	}
+	if s["endpoint"] == "" {
+		return nil, "", fmt.Errorf("remote %q has no endpoint", name)
+	}
+
+	endpoint := s["endpoint"]
+	// An endpoint without a scheme is https for rclone
+	if !strings.Contains(endpoint, "://") {
+		endpoint = "https://" + endpoint
+	}
+	cfg := config.Config{MinIO: config.MinIOConfig{
+		Endpoint:  endpoint,
+		AccessKey: s["access_key_id"],
+		SecretKey: s["secret_access_key"],
+	}}
+	cfg.Normalize()
+	return &cfg.MinIO, name, nil
+}
+
+// ReplaceRemote returns an rclone.conf with the named section replaced by
+// section, or with section appended when there is none
+func ReplaceRemote(data []byte, name, section string) []byte {
+	var out bytes.Buffer
+	skipping, replaced := false, false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			skipping = strings.TrimSpace(trimmed[1:len(trimmed)-1]) == name
+			if skipping && !replaced {
+				out.WriteString(section)
+				out.WriteString("\n")
+				replaced = true
+			}
+		}
+		if !skipping {
+			out.WriteString(line)
+			out.WriteString("\n")
+		}
+	}
+
+	if !replaced {
+		if out.Len() > 0 && !bytes.HasSuffix(out.Bytes(), []byte("\n\n")) {
+			out.WriteString("\n")
+		}
+		out.WriteString(section)
+	}
+	return out.Bytes()
+}
+
+func listOrNone(names []string) string {
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
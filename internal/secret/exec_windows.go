@@ -0,0 +1,12 @@
+package secret
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// hideWindow keeps a console program from flashing a window when started
+// by the tray program
+func hideWindow(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+}
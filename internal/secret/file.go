@@ -0,0 +1,170 @@
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// fileName is the encrypted file store inside the secrets folder
+const fileName = "secrets.enc"
+
+// scrypt parameters for deriving the file key
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// sealedFile is the on-disk form of the file store
+type sealedFile struct {
+	Version int    `json:"version"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"` // AES-256-GCM sealed JSON map of name to secret
+}
+
+// fileStore keeps every secret in one file, encrypted with a key derived
+// from a passphrase
+type fileStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+func openFile() (Store, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	return &fileStore{path: filepath.Join(d, fileName)}, nil
+}
+
+func (s *fileStore) Get(name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, _, err := s.load()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *fileStore) Set(name, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, passphrase, err := s.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return s.save(secrets, passphrase)
+}
+
+func (s *fileStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secrets, passphrase, err := s.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return ErrNotFound
+	}
+	delete(secrets, name)
+	return s.save(secrets, passphrase)
+}
+
+// load decrypts the store, returning an empty one when the file does not
+// exist yet
+func (s *fileStore) load() (map[string]string, string, error) {
+	passphrase, err := Passphrase()
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, passphrase, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	var sealed sealedFile
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, "", fmt.Errorf("corrupt secret file %s: %w", s.path, err)
+	}
+	gcm, err := newGCM(passphrase, sealed.Salt)
+	if err != nil {
+		return nil, "", err
+	}
+	plain, err := gcm.Open(nil, sealed.Nonce, sealed.Data, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("wrong passphrase for %s", s.path)
+	}
+
+	secrets := map[string]string{}
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return nil, "", fmt.Errorf("corrupt secret file %s: %w", s.path, err)
+	}
+	return secrets, passphrase, nil
+}
+
+// save encrypts the store with a fresh salt and nonce and replaces the file
+func (s *fileStore) save(secrets map[string]string, passphrase string) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	sealed := sealedFile{Version: 1, Salt: make([]byte, 16)}
+	if _, err := rand.Read(sealed.Salt); err != nil {
+		return err
+	}
+	gcm, err := newGCM(passphrase, sealed.Salt)
+	if err != nil {
+		return err
+	}
+	sealed.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(sealed.Nonce); err != nil {
+		return err
+	}
+	sealed.Data = gcm.Seal(nil, sealed.Nonce, plain, nil)
+
+	data, err := json.MarshalIndent(&sealed, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
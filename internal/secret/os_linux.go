@@ -0,0 +1,174 @@
+package secret
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Secret Service names, see the freedesktop.org Secret Service API
+const (
+	ssDest       = "org.freedesktop.secrets"
+	ssPath       = "/org/freedesktop/secrets"
+	ssDefault    = "/org/freedesktop/secrets/aliases/default"
+	ssService    = "org.freedesktop.Secret.Service"
+	ssCollection = "org.freedesktop.Secret.Collection"
+	ssItem       = "org.freedesktop.Secret.Item"
+	ssPrompt     = "org.freedesktop.Secret.Prompt"
+)
+
+// application tags every item this program stores
+const application = "minio-drive"
+
+// ssSecret is the Secret struct of the Secret Service API
+type ssSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+// secretService stores secrets in the desktop keyring (GNOME Keyring,
+// KWallet) through the Secret Service D-Bus API
+type secretService struct {
+	conn    *dbus.Conn
+	session dbus.ObjectPath
+}
+
+func openOS() (Store, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("%w: no D-Bus session: %v", ErrUnsupported, err)
+	}
+
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	err = conn.Object(ssDest, ssPath).
+		Call(ssService+".OpenSession", 0, "plain", dbus.MakeVariant("")).
+		Store(&output, &session)
+	if err != nil {
+		return nil, fmt.Errorf("%w: Secret Service unavailable: %v", ErrUnsupported, err)
+	}
+	return &secretService{conn: conn, session: session}, nil
+}
+
+func attributes(name string) map[string]string {
+	return map[string]string{"application": application, "name": name}
+}
+
+// find returns the item holding a secret, unlocking it when needed
+func (s *secretService) find(name string) (dbus.ObjectPath, error) {
+	var unlocked, locked []dbus.ObjectPath
+	err := s.conn.Object(ssDest, ssPath).
+		Call(ssService+".SearchItems", 0, attributes(name)).
+		Store(&unlocked, &locked)
+	if err != nil {
+		return "", err
+	}
+	if len(unlocked) > 0 {
+		return unlocked[0], nil
+	}
+	if len(locked) == 0 {
+		return "", ErrNotFound
+	}
+
+	var prompt dbus.ObjectPath
+	err = s.conn.Object(ssDest, ssPath).
+		Call(ssService+".Unlock", 0, locked[:1]).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return "", err
+	}
+	if err := s.prompt(prompt); err != nil {
+		return "", err
+	}
+	return locked[0], nil
+}
+
+func (s *secretService) Get(name string) (string, error) {
+	item, err := s.find(name)
+	if err != nil {
+		return "", err
+	}
+
+	var secret ssSecret
+	if err := s.conn.Object(ssDest, item).Call(ssItem+".GetSecret", 0, s.session).Store(&secret); err != nil {
+		return "", err
+	}
+	return string(secret.Value), nil
+}
+
+func (s *secretService) Set(name, value string) error {
+	collection := s.conn.Object(ssDest, ssDefault)
+
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	err := s.conn.Object(ssDest, ssPath).
+		Call(ssService+".Unlock", 0, []dbus.ObjectPath{ssDefault}).
+		Store(&unlocked, &prompt)
+	if err != nil {
+		return err
+	}
+	if err := s.prompt(prompt); err != nil {
+		return err
+	}
+
+	props := map[string]dbus.Variant{
+		ssItem + ".Label":      dbus.MakeVariant("MinIO Drive: " + name),
+		ssItem + ".Attributes": dbus.MakeVariant(attributes(name)),
+	}
+	secret := ssSecret{Session: s.session, Value: []byte(value), ContentType: "text/plain"}
+
+	var item dbus.ObjectPath
+	err = collection.Call(ssCollection+".CreateItem", 0, props, secret, true).Store(&item, &prompt)
+	if err != nil {
+		return err
+	}
+	return s.prompt(prompt)
+}
+
+func (s *secretService) Delete(name string) error {
+	item, err := s.find(name)
+	if err != nil {
+		return err
+	}
+
+	var prompt dbus.ObjectPath
+	if err := s.conn.Object(ssDest, item).Call(ssItem+".Delete", 0).Store(&prompt); err != nil {
+		return err
+	}
+	return s.prompt(prompt)
+}
+
+// prompt shows a keyring prompt, such as the unlock dialog, and waits for
+// the user to answer it. "/" means no prompt is needed.
+func (s *secretService) prompt(path dbus.ObjectPath) error {
+	if path == "/" || path == "" {
+		return nil
+	}
+
+	match := []dbus.MatchOption{dbus.WithMatchObjectPath(path), dbus.WithMatchInterface(ssPrompt)}
+	if err := s.conn.AddMatchSignal(match...); err != nil {
+		return err
+	}
+	defer s.conn.RemoveMatchSignal(match...)
+
+	signals := make(chan *dbus.Signal, 1)
+	s.conn.Signal(signals)
+	defer s.conn.RemoveSignal(signals)
+
+	if err := s.conn.Object(ssDest, path).Call(ssPrompt+".Prompt", 0, "").Err; err != nil {
+		return err
+	}
+	for sig := range signals {
+		if sig.Path != path || sig.Name != ssPrompt+".Completed" || len(sig.Body) < 1 {
+			continue
+		}
+		if dismissed, _ := sig.Body[0].(bool); dismissed {
+			return errors.New("keyring prompt was dismissed")
+		}
+		return nil
+	}
+	return errors.New("D-Bus connection closed while waiting for the keyring prompt")
+}
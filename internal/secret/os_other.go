@@ -0,0 +1,7 @@
+//go:build !windows && !linux
+
+package secret
+
+func openOS() (Store, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,90 @@
+package secret
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// dpapiStore keeps each secret in its own file, encrypted with DPAPI so
+// only the current Windows user can read it
+type dpapiStore struct {
+	dir string
+}
+
+func openOS() (Store, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+	return &dpapiStore{dir: d}, nil
+}
+
+func (s *dpapiStore) path(name string) string {
+	return filepath.Join(s.dir, name+".dpapi")
+}
+
+func (s *dpapiStore) Get(name string) (string, error) {
+	data, err := os.ReadFile(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	plain, err := unprotect(data)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func (s *dpapiStore) Set(name, value string) error {
+	data, err := protect([]byte(value))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path(name), data, 0600)
+}
+
+func (s *dpapiStore) Delete(name string) error {
+	err := os.Remove(s.path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func protect(plain []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptProtectData(blob(plain), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+func unprotect(sealed []byte) ([]byte, error) {
+	var out windows.DataBlob
+	if err := windows.CryptUnprotectData(blob(sealed), nil, nil, 0, nil, windows.CRYPTPROTECT_UI_FORBIDDEN, &out); err != nil {
+		return nil, err
+	}
+	return takeBlob(&out), nil
+}
+
+func blob(b []byte) *windows.DataBlob {
+	if len(b) == 0 {
+		return &windows.DataBlob{}
+	}
+	return &windows.DataBlob{Size: uint32(len(b)), Data: &b[0]}
+}
+
+// takeBlob copies a blob allocated by DPAPI and frees it
+func takeBlob(b *windows.DataBlob) []byte {
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(b.Data)))
+	out := make([]byte, b.Size)
+	copy(out, unsafe.Slice(b.Data, b.Size))
+	return out
+}
@@ -0,0 +1,160 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RefreshBefore is how long before their expiry process credentials are
+// fetched again, so a request never goes out with keys about to lapse
+const RefreshBefore = 5 * time.Minute
+
+// processTimeout bounds one run of a credential process
+const processTimeout = time.Minute
+
+// Credentials are the keys printed by a credential process, in the JSON
+// format of the AWS CLI credential_process setting
+type Credentials struct {
+	Version         int       `json:"Version"`
+	AccessKeyID     string    `json:"AccessKeyId"`
+	SecretAccessKey string    `json:"SecretAccessKey"`
+	SessionToken    string    `json:"SessionToken,omitempty"`
+	Expiration      time.Time `json:"Expiration,omitempty"` // zero when the keys do not expire
+}
+
+// Process runs an external command for credentials and caches them until
+// shortly before they expire
+type Process struct {
+	command string
+
+	mu     sync.Mutex
+	cached *Credentials
+}
+
+var (
+	processesMu sync.Mutex
+	processes   = map[string]*Process{}
+)
+
+// ProcessFor returns the shared Process for a command line, so the MinIO
+// client and rclone in one program reuse the same credentials
+func ProcessFor(command string) *Process {
+	processesMu.Lock()
+	defer processesMu.Unlock()
+
+	p, ok := processes[command]
+	if !ok {
+		p = &Process{command: command}
+		processes[command] = p
+	}
+	return p
+}
+
+// Get returns the cached credentials, running the command when there are
+// none or they expire within RefreshBefore
+func (p *Process) Get(ctx context.Context) (Credentials, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cached != nil && !expiring(p.cached) {
+		return *p.cached, nil
+	}
+
+	creds, err := p.run(ctx)
+	if err != nil {
+		return Credentials{}, err
+	}
+	p.cached = creds
+	return *creds, nil
+}
+
+// Expired reports whether the next Get will run the command again
+func (p *Process) Expired() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cached == nil || expiring(p.cached)
+}
+
+func expiring(c *Credentials) bool {
+	return !c.Expiration.IsZero() && time.Until(c.Expiration) < RefreshBefore
+}
+
+func (p *Process) run(ctx context.Context) (*Credentials, error) {
+	args, err := splitCommand(p.command)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, processTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	hideWindow(cmd)
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("credential process %s failed: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("credential process %s failed: %w", args[0], err)
+	}
+
+	var creds Credentials
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return nil, fmt.Errorf("credential process %s printed invalid JSON: %w", args[0], err)
+	}
+	switch {
+	case creds.Version != 0 && creds.Version != 1:
+		return nil, fmt.Errorf("credential process %s printed unsupported Version %d", args[0], creds.Version)
+	case creds.AccessKeyID == "" || creds.SecretAccessKey == "":
+		return nil, fmt.Errorf("credential process %s did not print AccessKeyId and SecretAccessKey", args[0])
+	case !creds.Expiration.IsZero() && time.Until(creds.Expiration) <= 0:
+		return nil, fmt.Errorf("credential process %s returned credentials that expired at %s",
+			args[0], creds.Expiration.Format(time.RFC3339))
+	}
+	return &creds, nil
+}
+
+// splitCommand splits a command line on spaces, keeping double-quoted
+// parts such as "C:\Program Files\tool.exe" together
+func splitCommand(s string) ([]string, error) {
+	var args []string
+	var cur strings.Builder
+	inQuote, inArg := false, false
+
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			inArg = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if inArg {
+				args = append(args, cur.String())
+				cur.Reset()
+				inArg = false
+			}
+		default:
+			cur.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inQuote {
+		return nil, errors.New("unterminated quote in credential_process")
+	}
+	if inArg {
+		args = append(args, cur.String())
+	}
+	if len(args) == 0 {
+		return nil, errors.New("credential_process is empty")
+	}
+	return args, nil
+}
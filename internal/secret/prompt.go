@@ -0,0 +1,53 @@
+package secret
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// stdin is shared so that several prompts read consecutive lines
+var stdin = bufio.NewReader(os.Stdin)
+
+// ReadLine prints a prompt and reads one line from stdin. With hidden set,
+// the typed text is not echoed.
+func ReadLine(prompt string, hidden bool) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	read := func() (string, error) {
+		line, err := stdin.ReadString('\n')
+		if err != nil && line == "" {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+	if !hidden {
+		return read()
+	}
+	line, err := withoutEcho(read)
+	fmt.Fprintln(os.Stderr)
+	return line, err
+}
+
+// PromptPassphrase returns a Passphrase function that uses PassphraseEnv
+// when set and otherwise asks once on the terminal
+func PromptPassphrase() func() (string, error) {
+	var cached string
+	return func() (string, error) {
+		if p := os.Getenv(PassphraseEnv); p != "" {
+			return p, nil
+		}
+		if cached != "" {
+			return cached, nil
+		}
+		p, err := ReadLine("Secret store passphrase: ", true)
+		if err != nil {
+			return "", err
+		}
+		if p == "" {
+			return "", fmt.Errorf("empty passphrase")
+		}
+		cached = p
+		return p, nil
+	}
+}
@@ -0,0 +1,128 @@
+// Package secret keeps credentials out of config.json. A config refers to a
+// secret as "<store>:<name>", for example "os:prod" or "file:prod".
+package secret
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Store backends
+const (
+	StoreOS   = "os"   // DPAPI on Windows, the Secret Service on Linux
+	StoreFile = "file" // a file encrypted with a passphrase
+)
+
+// PassphraseEnv may hold the passphrase of the file store, for programs
+// that cannot prompt for it
+const PassphraseEnv = "MINIODRIVE_SECRET_PASSPHRASE"
+
+var (
+	// ErrNotFound is returned when a store has no secret of that name
+	ErrNotFound = errors.New("secret not found")
+
+	// ErrUnsupported is returned when the OS store is not available
+	ErrUnsupported = errors.New("secret store not supported on this system")
+)
+
+// Passphrase supplies the passphrase of the file store. It reads
+// PassphraseEnv by default; interactive programs may replace it with a
+// prompt.
+var Passphrase = func() (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+	return "", fmt.Errorf("the file secret store is locked; set %s", PassphraseEnv)
+}
+
+// Store reads and writes named secrets
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// Ref identifies a secret in a store
+type Ref struct {
+	Store string
+	Name  string
+}
+
+func (r Ref) String() string {
+	return r.Store + ":" + r.Name
+}
+
+// ParseRef parses "<store>:<name>"
+func ParseRef(s string) (Ref, error) {
+	store, name, ok := strings.Cut(s, ":")
+	if !ok || name == "" {
+		return Ref{}, fmt.Errorf("secret reference %q must look like \"os:<name>\" or \"file:<name>\"", s)
+	}
+	if store != StoreOS && store != StoreFile {
+		return Ref{}, fmt.Errorf("unknown secret store %q in %q, use %q or %q", store, s, StoreOS, StoreFile)
+	}
+	if strings.ContainsAny(name, `/\:*?"<>|`) {
+		return Ref{}, fmt.Errorf("secret name %q may not contain any of / \\ : * ? \" < > |", name)
+	}
+	return Ref{Store: store, Name: name}, nil
+}
+
+// Open returns the named store
+func Open(store string) (Store, error) {
+	switch store {
+	case StoreOS:
+		return openOS()
+	case StoreFile:
+		return openFile()
+	}
+	return nil, fmt.Errorf("unknown secret store %q", store)
+}
+
+// Lookup returns the secret a reference points to
+func Lookup(ref string) (string, error) {
+	r, err := ParseRef(ref)
+	if err != nil {
+		return "", err
+	}
+	s, err := Open(r.Store)
+	if err != nil {
+		return "", err
+	}
+	value, err := s.Get(r.Name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret %s: %w", r, err)
+	}
+	return value, nil
+}
+
+// Put stores value under a reference, replacing any previous value
+func Put(ref, value string) error {
+	r, err := ParseRef(ref)
+	if err != nil {
+		return err
+	}
+	s, err := Open(r.Store)
+	if err != nil {
+		return err
+	}
+	if err := s.Set(r.Name, value); err != nil {
+		return fmt.Errorf("failed to store secret %s: %w", r, err)
+	}
+	return nil
+}
+
+// dir is where stores that keep files put them
+func dir() (string, error) {
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	d := filepath.Join(base, "MinIODrive", "secrets")
+	if err := os.MkdirAll(d, 0700); err != nil {
+		return "", err
+	}
+	return d, nil
+}
@@ -0,0 +1,23 @@
+package secret
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// withoutEcho runs read with terminal echo turned off
+func withoutEcho(read func() (string, error)) (string, error) {
+	fd := int(os.Stdin.Fd())
+	old, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return read() // not a terminal, e.g. piped input
+	}
+	quiet := *old
+	quiet.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &quiet); err != nil {
+		return read()
+	}
+	defer unix.IoctlSetTermios(fd, unix.TCSETS, old)
+	return read()
+}
@@ -0,0 +1,8 @@
+//go:build !windows && !linux
+
+package secret
+
+// withoutEcho cannot turn off echo here, so the input stays visible
+func withoutEcho(read func() (string, error)) (string, error) {
+	return read()
+}
@@ -0,0 +1,21 @@
+package secret
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// withoutEcho runs read with console echo turned off
+func withoutEcho(read func() (string, error)) (string, error) {
+	h := windows.Handle(os.Stdin.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(h, &mode); err != nil {
+		return read() // not a console, e.g. piped input
+	}
+	if err := windows.SetConsoleMode(h, mode&^windows.ENABLE_ECHO_INPUT); err != nil {
+		return read()
+	}
+	defer windows.SetConsoleMode(h, mode)
+	return read()
+}
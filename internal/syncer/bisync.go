@@ -0,0 +1,400 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/filter"
+	"simple-uploader/internal/minio"
+)
+
+// BiSyncOptions controls a two-way sync
+type BiSyncOptions struct {
+	MaxDelete        int     // abort when more deletions are planned on either side, 0 for no limit
+	MaxDeletePercent float64 // abort when more than this share of either side would go, 0 for no limit
+}
+
+// BiSync keeps a local folder and a bucket prefix in step in both
+// directions. A state file remembers each path as it was after the last
+// sync, which is what tells local edits, remote edits and conflicts apart.
+type BiSync struct {
+	Client    *minio.Client
+	LocalDir  string
+	Prefix    string
+	StatePath string
+	Filters   []string // gitignore-style patterns applied with the folder's .minioignore files
+	Options   BiSyncOptions
+
+	state *State
+}
+
+// Plan compares both sides with the last synced state
+func (b *BiSync) Plan(ctx context.Context) (*Plan, error) {
+	prefix := normalizePrefix(b.Prefix)
+
+	st, err := LoadState(b.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	b.state = st
+
+	ignore := filter.New(b.LocalDir, b.Filters)
+	statePath := b.StatePath
+	local, err := scanLocal(b.LocalDir, func(rel string, isDir bool) bool {
+		return ignore.Excluded(rel, isDir) || filepath.Join(b.LocalDir, filepath.FromSlash(rel)) == statePath
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", b.LocalDir, err)
+	}
+	remote, err := scanRemote(ctx, b.Client, prefix, ignore.Excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{
+		Remote: len(remote),
+		Local:  len(local),
+		record: make(map[string]StateEntry),
+	}
+
+	paths := make(map[string]bool)
+	for rel := range local {
+		paths[rel] = true
+	}
+	for rel := range remote {
+		paths[rel] = true
+	}
+	for rel := range st.Entries {
+		paths[rel] = true
+	}
+
+	for _, rel := range sortedKeys(paths) {
+		file, hasLocal := local[rel]
+		obj, hasRemote := remote[rel]
+		entry, synced := st.Entries[rel]
+
+		localChanged := hasLocal && (!synced || file.Size != entry.Size || !sameModTime(file.ModTime, entry.ModTime))
+		remoteChanged := hasRemote && (!synced || obj.ETag != entry.ETag)
+
+		action := Action{Path: rel, Key: objectKey(prefix, rel)}
+		if hasLocal {
+			action.local = &file
+		}
+		if hasRemote {
+			action.remote = &obj
+		}
+		switch {
+		case hasLocal && hasRemote:
+			switch {
+			case !localChanged && !remoteChanged:
+				plan.Unchanged++
+				continue
+			case localChanged && !remoteChanged:
+				action.Op, action.Size, action.Reason = OpUpload, file.Size, "changed locally"
+			case !localChanged && remoteChanged:
+				action.Op, action.Size, action.Reason = OpDownload, obj.Size, "changed remotely"
+			default:
+				same, err := sameContent(file, obj)
+				if err != nil {
+					return nil, err
+				}
+				if same {
+					plan.record[rel] = StateEntry{Size: file.Size, ModTime: file.ModTime, ETag: obj.ETag}
+					plan.Unchanged++
+					continue
+				}
+				action.Op, action.Size, action.Reason = OpConflict, file.Size, "changed on both sides"
+			}
+
+		case hasLocal:
+			if synced && !localChanged {
+				action.Op, action.Size, action.Reason = OpDeleteLocal, file.Size, "deleted remotely"
+			} else if synced {
+				// Keep the local edit rather than losing it to the remote delete
+				action.Op, action.Size, action.Reason = OpUpload, file.Size, "changed locally, deleted remotely"
+			} else {
+				action.Op, action.Size, action.Reason = OpUpload, file.Size, "new locally"
+			}
+
+		case hasRemote:
+			if synced && !remoteChanged {
+				action.Op, action.Size, action.Reason = OpDeleteRemote, obj.Size, "deleted locally"
+			} else if synced {
+				action.Op, action.Size, action.Reason = OpDownload, obj.Size, "changed remotely, deleted locally"
+			} else {
+				action.Op, action.Size, action.Reason = OpDownload, obj.Size, "new remotely"
+			}
+
+		default:
+			plan.forget = append(plan.forget, rel)
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, action)
+	}
+
+	if err := checkDeletes(plan, b.Options.MaxDelete, b.Options.MaxDeletePercent); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// sameContent reports whether a local file and an object hold the same bytes
+func sameContent(file LocalFile, obj minio.Object) (bool, error) {
+	if file.Size != obj.Size || obj.MD5 == "" {
+		return false, nil
+	}
+	sum, err := minio.FileMD5(file.Path)
+	if err != nil {
+		return false, err
+	}
+	return sum == obj.MD5, nil
+}
+
+// Apply carries out a plan made by Plan and saves the updated state.
+// report is called after each action, with a nil error on success.
+func (b *BiSync) Apply(ctx context.Context, plan *Plan, report func(Action, error)) error {
+	if b.state == nil {
+		return fmt.Errorf("apply called before plan")
+	}
+
+	st := b.state
+	st.LocalDir = b.LocalDir
+	st.Bucket = b.Client.Bucket()
+	st.Prefix = normalizePrefix(b.Prefix)
+
+	for rel, entry := range plan.record {
+		st.Entries[rel] = entry
+	}
+	for _, rel := range plan.forget {
+		delete(st.Entries, rel)
+	}
+
+	var failed int
+	for _, a := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			failed++
+			break
+		}
+
+		err := b.apply(ctx, a)
+		if err != nil {
+			failed++
+		}
+		if report != nil {
+			report(a, err)
+		}
+	}
+
+	st.SyncedAt = time.Now()
+	if err := st.Save(b.StatePath); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d actions failed", failed, len(plan.Actions))
+	}
+	return nil
+}
+
+// ErrChangedSincePlan is reported for an action that was skipped because
+// its file or object changed after the plan was made. The next sync plans
+// the path again from what is there.
+var ErrChangedSincePlan = errors.New("changed since the plan was made, skipped")
+
+// apply performs one action and records the result in the state. Nothing
+// is overwritten or deleted unless both sides are still as planned.
+func (b *BiSync) apply(ctx context.Context, a Action) error {
+	localPath := filepath.Join(b.LocalDir, filepath.FromSlash(a.Path))
+
+	switch a.Op {
+	case OpUpload:
+		if err := b.checkRemote(ctx, a); err != nil {
+			return err
+		}
+		etag := ""
+		if a.remote != nil {
+			etag = a.remote.ETag
+		}
+		return b.upload(ctx, localPath, a.Path, a.Key, etag)
+
+	case OpDownload:
+		if err := checkLocal(a, localPath); err != nil {
+			return err
+		}
+		return b.download(ctx, a.Key, a.remote.ETag, localPath, a.Path)
+
+	case OpDeleteRemote:
+		// The client has no conditional delete, so the object is checked
+		// right before it is removed
+		if err := checkLocal(a, localPath); err != nil {
+			return err
+		}
+		if err := b.checkRemote(ctx, a); err != nil {
+			return err
+		}
+		if err := b.Client.RemoveObject(ctx, a.Key); err != nil {
+			return err
+		}
+		delete(b.state.Entries, a.Path)
+		return nil
+
+	case OpDeleteLocal:
+		if err := b.checkRemote(ctx, a); err != nil {
+			return err
+		}
+		if err := checkLocal(a, localPath); err != nil {
+			return err
+		}
+		if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		delete(b.state.Entries, a.Path)
+		return nil
+
+	case OpConflict:
+		// Keep the local version as a conflict copy on both sides and take
+		// the remote version under the original name. Nothing is lost if
+		// either side changed again meanwhile: the local file is moved,
+		// not overwritten, and the newest remote version is downloaded.
+		copyRel := conflictName(a.Path, time.Now())
+		copyPath := filepath.Join(b.LocalDir, filepath.FromSlash(copyRel))
+		if err := os.Rename(localPath, copyPath); err != nil {
+			return fmt.Errorf("failed to keep conflict copy: %w", err)
+		}
+		if err := b.upload(ctx, copyPath, copyRel, objectKey(normalizePrefix(b.Prefix), copyRel), ""); err != nil {
+			return err
+		}
+		return b.download(ctx, a.Key, "", localPath, a.Path)
+	}
+
+	return fmt.Errorf("unknown action %q", a.Op)
+}
+
+// checkLocal returns ErrChangedSincePlan when the local file is no longer
+// as the plan saw it: changed, deleted, or created where there was none
+func checkLocal(a Action, localPath string) error {
+	info, err := os.Stat(localPath)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return err
+	case a.local == nil && err == nil:
+		return fmt.Errorf("%w: %s was created locally", ErrChangedSincePlan, a.Path)
+	case a.local == nil:
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w: %s was deleted locally", ErrChangedSincePlan, a.Path)
+	case info.Size() != a.local.Size || !sameModTime(info.ModTime(), a.local.ModTime):
+		return fmt.Errorf("%w: %s was modified locally", ErrChangedSincePlan, a.Path)
+	}
+	return nil
+}
+
+// checkRemote returns ErrChangedSincePlan when the object is no longer as
+// the plan saw it: a different ETag, deleted, or created where there was none
+func (b *BiSync) checkRemote(ctx context.Context, a Action) error {
+	obj, err := b.Client.StatObject(ctx, a.Key)
+	switch {
+	case err != nil && !minio.IsNotFound(err):
+		return err
+	case a.remote == nil && err == nil:
+		return fmt.Errorf("%w: %s was created remotely", ErrChangedSincePlan, a.Key)
+	case a.remote == nil:
+		return nil
+	case err != nil:
+		return fmt.Errorf("%w: %s was deleted remotely", ErrChangedSincePlan, a.Key)
+	case obj.ETag != a.remote.ETag:
+		return fmt.Errorf("%w: %s was modified remotely", ErrChangedSincePlan, a.Key)
+	}
+	return nil
+}
+
+// upload sends a local file, replacing the object only while it still has
+// matchETag when that is set
+func (b *BiSync) upload(ctx context.Context, localPath, rel, key, matchETag string) error {
+	client := b.Client
+	if matchETag != "" {
+		opts := client.UploadOptions()
+		opts.MatchETag = matchETag
+		c, err := client.WithUploadOptions(opts)
+		if err != nil {
+			return err
+		}
+		client = c
+	}
+
+	file, err := uploadFile(ctx, client, localPath, key)
+	if minio.IsPreconditionFailed(err) {
+		return fmt.Errorf("%w: %s was modified remotely", ErrChangedSincePlan, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	obj, err := b.Client.StatObject(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	b.state.Entries[rel] = StateEntry{Size: file.Size, ModTime: file.ModTime, ETag: obj.ETag}
+	return nil
+}
+
+// download fetches an object over a local file, only while the object
+// still has matchETag when that is set
+func (b *BiSync) download(ctx context.Context, key, matchETag, localPath, rel string) error {
+	obj, err := b.Client.StatObject(ctx, key)
+	if err != nil {
+		return err
+	}
+	if matchETag != "" && obj.ETag != matchETag {
+		return fmt.Errorf("%w: %s was modified remotely", ErrChangedSincePlan, key)
+	}
+
+	if matchETag != "" {
+		err = b.Client.DownloadFileIfMatch(ctx, key, matchETag, localPath)
+	} else {
+		err = b.Client.DownloadFile(ctx, key, localPath)
+	}
+	if minio.IsPreconditionFailed(err) {
+		return fmt.Errorf("%w: %s was modified remotely", ErrChangedSincePlan, key)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Carry the original modification time over when it was recorded
+	modTime := obj.ModTime
+	if modTime.IsZero() {
+		modTime = obj.LastModified
+	}
+	_ = os.Chtimes(localPath, modTime, modTime)
+
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return err
+	}
+
+	b.state.Entries[rel] = StateEntry{Size: info.Size(), ModTime: info.ModTime(), ETag: obj.ETag}
+	return nil
+}
+
+// conflictName returns "name (conflict host date).ext" next to rel
+func conflictName(rel string, now time.Time) string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "unknown"
+	}
+
+	dir, name := path.Split(rel)
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	return dir + fmt.Sprintf("%s (conflict %s %s)%s", base, host, now.Format("2006-01-02 150405"), ext)
+}
@@ -0,0 +1,264 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"simple-uploader/internal/filter"
+	"simple-uploader/internal/minio"
+)
+
+// Op is the kind of change a sync plan makes
+type Op string
+
+const (
+	OpUpload       Op = "upload"
+	OpDownload     Op = "download"
+	OpDeleteRemote Op = "delete-remote"
+	OpDeleteLocal  Op = "delete-local"
+	OpConflict     Op = "conflict"
+)
+
+// Action is one planned change
+type Action struct {
+	Op     Op     `json:"op"`
+	Path   string `json:"path"` // relative, slash-separated
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	Reason string `json:"reason"`
+
+	// What a two-way plan saw on each side, nil where the path was
+	// missing. Apply checks both again before it touches any data.
+	local  *LocalFile
+	remote *minio.Object
+}
+
+// Plan is the list of changes needed to bring the remote in line
+type Plan struct {
+	Actions   []Action `json:"actions"`
+	Unchanged int      `json:"unchanged"`
+	Remote    int      `json:"remote_objects"`
+	Local     int      `json:"local_files"`
+
+	// Two-way sync bookkeeping that does not touch any data
+	record map[string]StateEntry // identical on both sides, state missing
+	forget []string              // gone on both sides, state left over
+}
+
+// Count returns the number of planned actions of the given kind
+func (p *Plan) Count(op Op) int {
+	n := 0
+	for _, a := range p.Actions {
+		if a.Op == op {
+			n++
+		}
+	}
+	return n
+}
+
+// ErrTooManyDeletes is returned when a plan exceeds the delete safety threshold
+var ErrTooManyDeletes = errors.New("too many deletions planned")
+
+// MirrorOptions controls a one-way mirror
+type MirrorOptions struct {
+	Delete           bool    // delete remote objects that no longer exist locally
+	MaxDelete        int     // abort when more deletions are planned, 0 for no limit
+	MaxDeletePercent float64 // abort when more than this share of remote objects would go, 0 for no limit
+}
+
+// Mirror makes a bucket prefix match a local folder
+type Mirror struct {
+	Client   *minio.Client
+	LocalDir string
+	Prefix   string
+	Filters  []string // gitignore-style patterns applied with the folder's .minioignore files
+	Options  MirrorOptions
+}
+
+// Plan compares the local folder with the prefix. Files are considered
+// unchanged when size and recorded mtime match; otherwise their MD5 is
+// compared with the stored one before deciding to upload.
+func (m *Mirror) Plan(ctx context.Context) (*Plan, error) {
+	prefix := normalizePrefix(m.Prefix)
+
+	ignore := filter.New(m.LocalDir, m.Filters)
+	local, err := scanLocal(m.LocalDir, ignore.Excluded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", m.LocalDir, err)
+	}
+	remote, err := scanRemote(ctx, m.Client, prefix, ignore.Excluded)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &Plan{Remote: len(remote), Local: len(local)}
+	for _, rel := range sortedKeys(local) {
+		file := local[rel]
+		obj, ok := remote[rel]
+
+		reason, err := uploadReason(file, obj, ok)
+		if err != nil {
+			return nil, err
+		}
+		if reason == "" {
+			plan.Unchanged++
+			continue
+		}
+
+		plan.Actions = append(plan.Actions, Action{
+			Op:     OpUpload,
+			Path:   rel,
+			Key:    objectKey(prefix, rel),
+			Size:   file.Size,
+			Reason: reason,
+		})
+	}
+
+	if m.Options.Delete {
+		for _, rel := range sortedKeys(remote) {
+			if _, ok := local[rel]; ok {
+				continue
+			}
+			obj := remote[rel]
+			plan.Actions = append(plan.Actions, Action{
+				Op:     OpDeleteRemote,
+				Path:   rel,
+				Key:    obj.Key,
+				Size:   obj.Size,
+				Reason: "removed locally",
+			})
+		}
+	}
+
+	if err := checkDeletes(plan, m.Options.MaxDelete, m.Options.MaxDeletePercent); err != nil {
+		return plan, err
+	}
+	return plan, nil
+}
+
+// uploadReason explains why a local file needs uploading, or returns ""
+func uploadReason(file LocalFile, obj minio.Object, exists bool) (string, error) {
+	if !exists {
+		return "new", nil
+	}
+	if file.Size != obj.Size {
+		return "size changed", nil
+	}
+	if !obj.ModTime.IsZero() && sameModTime(file.ModTime, obj.ModTime) {
+		return "", nil
+	}
+	if obj.MD5 == "" {
+		return "modified", nil
+	}
+
+	sum, err := minio.FileMD5(file.Path)
+	if err != nil {
+		return "", err
+	}
+	if sum != obj.MD5 {
+		return "content changed", nil
+	}
+	return "", nil
+}
+
+// checkDeletes enforces the delete safety threshold. The percentage is
+// taken against the side the deletions happen on.
+func checkDeletes(plan *Plan, maxDelete int, maxPercent float64) error {
+	sides := []struct {
+		op    Op
+		total int
+		where string
+	}{
+		{OpDeleteRemote, plan.Remote, "remote objects"},
+		{OpDeleteLocal, plan.Local, "local files"},
+	}
+
+	for _, side := range sides {
+		deletes := plan.Count(side.op)
+		if deletes == 0 {
+			continue
+		}
+
+		if maxDelete > 0 && deletes > maxDelete {
+			return fmt.Errorf("%w: %d %s planned, limit is %d", ErrTooManyDeletes, deletes, side.where, maxDelete)
+		}
+		if maxPercent > 0 && side.total > 0 {
+			percent := float64(deletes) * 100 / float64(side.total)
+			if percent > maxPercent {
+				return fmt.Errorf("%w: %.0f%% of %s planned, limit is %.0f%%",
+					ErrTooManyDeletes, percent, side.where, maxPercent)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply carries out a plan. report is called after each action, with a nil
+// error on success; failures do not stop the remaining actions.
+func (m *Mirror) Apply(ctx context.Context, plan *Plan, report func(Action, error)) error {
+	var failed int
+	for _, a := range plan.Actions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var err error
+		switch a.Op {
+		case OpUpload:
+			err = uploadLocal(ctx, m.Client, m.LocalDir, a)
+		case OpDeleteRemote:
+			err = m.Client.RemoveObject(ctx, a.Key)
+		}
+
+		if err != nil {
+			failed++
+		}
+		if report != nil {
+			report(a, err)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d actions failed", failed, len(plan.Actions))
+	}
+	return nil
+}
+
+// uploadLocal uploads a file with its mtime and MD5 recorded as metadata
+func uploadLocal(ctx context.Context, client *minio.Client, root string, a Action) error {
+	_, err := uploadFile(ctx, client, filepath.Join(root, filepath.FromSlash(a.Path)), a.Key)
+	return err
+}
+
+// uploadFile uploads a file with its mtime and MD5 recorded as metadata and
+// returns the local state that was uploaded
+func uploadFile(ctx context.Context, client *minio.Client, filePath, key string) (LocalFile, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return LocalFile{}, err
+	}
+	file := LocalFile{Path: filePath, Size: info.Size(), ModTime: info.ModTime()}
+
+	sum, err := minio.FileMD5(file.Path)
+	if err != nil {
+		return LocalFile{}, err
+	}
+
+	if err := client.UploadFileWithMetadata(ctx, file.Path, key, minio.FileMetadata(file.ModTime, sum)); err != nil {
+		return LocalFile{}, err
+	}
+	return file, nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
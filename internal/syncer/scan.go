@@ -0,0 +1,99 @@
+package syncer
+
+import (
+	"context"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"simple-uploader/internal/minio"
+)
+
+// mtimeWindow is the tolerance when comparing modification times, which
+// lose precision when stored as fractional seconds in object metadata
+const mtimeWindow = time.Millisecond
+
+// LocalFile is a file found under the local root
+type LocalFile struct {
+	Path    string // absolute path on disk
+	Size    int64
+	ModTime time.Time
+}
+
+// scanLocal walks root and returns its files keyed by slash-separated relative path
+func scanLocal(root string, skip func(rel string, isDir bool) bool) (map[string]LocalFile, error) {
+	files := make(map[string]LocalFile)
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if rel == "." {
+			return nil
+		}
+
+		if skip != nil && skip(rel, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		files[rel] = LocalFile{Path: p, Size: info.Size(), ModTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+// scanRemote lists the objects under prefix keyed by path relative to it.
+// Objects that skip matches are left out, so excluded files are neither
+// downloaded nor deleted.
+func scanRemote(ctx context.Context, client *minio.Client, prefix string, skip func(rel string, isDir bool) bool) (map[string]minio.Object, error) {
+	objects, err := client.ListObjects(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]minio.Object, len(objects))
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		if skip != nil && skip(rel, false) {
+			continue
+		}
+		result[rel] = obj
+	}
+	return result, nil
+}
+
+// normalizePrefix turns "a/b" or "/a/b/" into "a/b/", and "" stays ""
+func normalizePrefix(prefix string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return ""
+	}
+	return prefix + "/"
+}
+
+// objectKey joins a normalized prefix and a relative path
+func objectKey(prefix, rel string) string {
+	return prefix + path.Clean(rel)
+}
+
+func sameModTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d < mtimeWindow && d > -mtimeWindow
+}
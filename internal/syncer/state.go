@@ -0,0 +1,88 @@
+package syncer
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// StateEntry records a path as it was on both sides after the last sync
+type StateEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"` // local modification time
+	ETag    string    `json:"etag"`  // remote ETag
+}
+
+// State is the persistent record of the last successful sync of a pair
+type State struct {
+	LocalDir string                `json:"local_dir"`
+	Bucket   string                `json:"bucket"`
+	Prefix   string                `json:"prefix"`
+	SyncedAt time.Time             `json:"synced_at"`
+	Entries  map[string]StateEntry `json:"entries"`
+}
+
+// DefaultStatePath returns the state file for a folder and bucket prefix
+// under the user config dir, so the synced folder itself stays clean
+func DefaultStatePath(localDir, bucket, prefix string) (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(localDir)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha1.Sum([]byte(abs + "\x00" + bucket + "\x00" + normalizePrefix(prefix)))
+	name := hex.EncodeToString(sum[:8]) + ".json"
+	return filepath.Join(dir, "MinIODrive", "sync", name), nil
+}
+
+// LoadState reads a state file; a missing file yields an empty state
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &State{Entries: make(map[string]StateEntry)}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync state: %w", err)
+	}
+
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("failed to parse sync state %s: %w", path, err)
+	}
+	if st.Entries == nil {
+		st.Entries = make(map[string]StateEntry)
+	}
+	return &st, nil
+}
+
+// Save writes the state atomically
+func (s *State) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write sync state: %w", err)
+	}
+	return nil
+}
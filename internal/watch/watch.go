@@ -0,0 +1,424 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/filter"
+	"simple-uploader/internal/minio"
+	"simple-uploader/internal/policy"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// retryDelay is how long a file waits after a failed upload before the next attempt
+const retryDelay = time.Minute
+
+// uploadWorkers is how many files upload at once, so one large file does
+// not hold up the rest
+const uploadWorkers = 2
+
+// Watcher uploads files dropped into watched folders
+type Watcher struct {
+	Client    *minio.Client
+	Rules     []config.WatchRule
+	StableFor time.Duration // how long size and mtime must stay unchanged
+	Filters   []string      // gitignore-style patterns applied with each folder's .minioignore files
+
+	// OnUploaded is called after a file was uploaded (and moved, if configured)
+	OnUploaded func(rule config.WatchRule, filePath, key string)
+
+	// OnError is called when a file fails to upload or move. A file the
+	// server or the upload policy refuses is reported once, not on every
+	// retry.
+	OnError func(filePath string, err error)
+
+	mu       sync.Mutex
+	pending  map[string]*pendingFile
+	rejected map[string]fileVersion // refused for good until the file changes
+}
+
+// fileVersion identifies the contents of a file by size and mtime
+type fileVersion struct {
+	size    int64
+	modTime time.Time
+}
+
+// pendingFile is a file waiting to become stable
+type pendingFile struct {
+	rule      config.WatchRule
+	size      int64
+	modTime   time.Time
+	changedAt time.Time // last time size or mtime changed
+	notBefore time.Time // retry delay after a failure
+	uploading bool      // handed to a worker
+}
+
+// Run watches the configured folders until ctx is cancelled. Files already
+// present when it starts are picked up as well.
+func (w *Watcher) Run(ctx context.Context) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to start folder watcher: %w", err)
+	}
+	defer fw.Close()
+
+	w.pending = make(map[string]*pendingFile)
+	w.rejected = make(map[string]fileVersion)
+
+	for _, rule := range w.Rules {
+		if err := os.MkdirAll(rule.Folder, 0755); err != nil {
+			return fmt.Errorf("failed to create watch folder %s: %w", rule.Folder, err)
+		}
+		if err := w.addTree(fw, rule, rule.Folder); err != nil {
+			return err
+		}
+	}
+
+	// Uploads run on workers so events keep being handled meanwhile;
+	// Run returns once the uploads in progress have stopped
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < uploadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for filePath := range jobs {
+				w.uploadPending(ctx, filePath)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(jobs)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case event, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) || event.Has(fsnotify.Rename) {
+				w.handleEvent(fw, event.Name)
+			}
+
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			if w.OnError != nil {
+				w.OnError("", err)
+			}
+
+		case <-ticker.C:
+			w.dispatchStable(jobs)
+		}
+	}
+}
+
+// addTree watches dir (and its subfolders for recursive rules) and queues
+// the files already in it
+func (w *Watcher) addTree(fw *fsnotify.Watcher, rule config.WatchRule, dir string) error {
+	ignore := filter.New(rule.Folder, w.Filters)
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && (!rule.Recursive || w.isSentFolder(rule, p)) {
+				return filepath.SkipDir
+			}
+			if rel, err := filepath.Rel(rule.Folder, p); err == nil && rel != "." &&
+				ignore.Excluded(filepath.ToSlash(rel), true) {
+				return filepath.SkipDir
+			}
+			if err := fw.Add(p); err != nil {
+				return fmt.Errorf("failed to watch %s: %w", p, err)
+			}
+			return nil
+		}
+		w.track(rule, p)
+		return nil
+	})
+}
+
+func (w *Watcher) handleEvent(fw *fsnotify.Watcher, name string) {
+	rule, ok := w.ruleFor(name)
+	if !ok {
+		return
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return // renamed away or deleted
+	}
+	if info.IsDir() {
+		if rule.Recursive && !w.isSentFolder(rule, name) {
+			_ = w.addTree(fw, rule, name)
+		}
+		return
+	}
+
+	w.track(rule, name)
+}
+
+// track records a file as pending, resetting its stability timer on change.
+// Excluded files are ignored; ignore files are read again each time so edits
+// to them apply without a restart.
+func (w *Watcher) track(rule config.WatchRule, filePath string) {
+	info, err := os.Stat(filePath)
+	if err != nil || !info.Mode().IsRegular() {
+		return
+	}
+	rel, err := filepath.Rel(rule.Folder, filePath)
+	if err != nil || filter.New(rule.Folder, w.Filters).Excluded(filepath.ToSlash(rel), false) {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if v, ok := w.rejected[filePath]; ok {
+		if v.size == info.Size() && v.modTime.Equal(info.ModTime()) {
+			return
+		}
+		delete(w.rejected, filePath)
+	}
+
+	p, ok := w.pending[filePath]
+	if !ok {
+		w.pending[filePath] = &pendingFile{
+			rule:      rule,
+			size:      info.Size(),
+			modTime:   info.ModTime(),
+			changedAt: time.Now(),
+		}
+		return
+	}
+	if p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+		p.size, p.modTime, p.changedAt = info.Size(), info.ModTime(), time.Now()
+	}
+}
+
+// dispatchStable hands pending files whose size and mtime have not changed
+// for StableFor to an idle worker, so half-written files are never sent.
+// Files left over when all workers are busy wait for the next tick.
+func (w *Watcher) dispatchStable(jobs chan<- string) {
+	now := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for filePath, p := range w.pending {
+		if p.uploading {
+			continue
+		}
+		info, err := os.Stat(filePath)
+		if err != nil {
+			delete(w.pending, filePath)
+			continue
+		}
+		if p.size != info.Size() || !p.modTime.Equal(info.ModTime()) {
+			p.size, p.modTime, p.changedAt = info.Size(), info.ModTime(), now
+			continue
+		}
+		if now.Sub(p.changedAt) < w.StableFor || !now.After(p.notBefore) {
+			continue
+		}
+
+		select {
+		case jobs <- filePath:
+			p.uploading = true
+		default:
+			return
+		}
+	}
+}
+
+// uploadPending uploads one stable file on a worker and records the outcome
+func (w *Watcher) uploadPending(ctx context.Context, filePath string) {
+	w.mu.Lock()
+	p := w.pending[filePath]
+	w.mu.Unlock()
+	if p == nil || ctx.Err() != nil {
+		return
+	}
+
+	key, err := w.upload(ctx, p.rule, filePath)
+
+	w.mu.Lock()
+	p.uploading = false
+	switch {
+	case err == nil:
+		delete(w.pending, filePath)
+	case permanent(err):
+		delete(w.pending, filePath)
+		w.rejected[filePath] = fileVersion{p.size, p.modTime}
+	default:
+		p.notBefore = time.Now().Add(retryDelay)
+	}
+	w.mu.Unlock()
+
+	if err != nil {
+		if w.OnError != nil && ctx.Err() == nil {
+			w.OnError(filePath, err)
+		}
+		return
+	}
+	if w.OnUploaded != nil && key != "" {
+		w.OnUploaded(p.rule, filePath, key)
+	}
+}
+
+// permanent reports whether an upload failed in a way retrying cannot fix:
+// the upload policy or the server refused the file. Local file errors, such
+// as a file still locked by the program writing it, are retried.
+func permanent(err error) bool {
+	if errors.Is(err, policy.ErrRejected) {
+		return true
+	}
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	if errors.As(err, &pathErr) || errors.As(err, &linkErr) {
+		return false
+	}
+	return !minio.IsRetryable(err)
+}
+
+// upload sends one file and moves it to the sent folder. It returns "" as
+// the key when an identical object was already there.
+func (w *Watcher) upload(ctx context.Context, rule config.WatchRule, filePath string) (string, error) {
+	rel, err := filepath.Rel(rule.Folder, filePath)
+	if err != nil {
+		return "", err
+	}
+	key := objectKey(rule.Prefix, filepath.ToSlash(rel))
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	client, err := w.clientFor(rule)
+	if err != nil {
+		return "", err
+	}
+
+	// Files left in place are seen again on every start; skip those
+	// that were already uploaded unchanged
+	if obj, err := client.StatObject(ctx, key); err == nil &&
+		obj.Size == info.Size() && sameModTime(obj.ModTime, info.ModTime()) {
+		return "", w.moveToSent(rule, filePath, rel)
+	}
+
+	sum, err := minio.FileMD5(filePath)
+	if err != nil {
+		return "", err
+	}
+	if err := client.UploadFileWithMetadata(ctx, filePath, key, minio.FileMetadata(info.ModTime(), sum)); err != nil {
+		return "", err
+	}
+
+	return key, w.moveToSent(rule, filePath, rel)
+}
+
+// clientFor returns a client applying the rule's storage class and tags
+func (w *Watcher) clientFor(rule config.WatchRule) (*minio.Client, error) {
+	if rule.StorageClass == "" && len(rule.Tags) == 0 {
+		return w.Client, nil
+	}
+	opts := w.Client.UploadOptions().WithStorageClass(rule.StorageClass).WithTags(rule.Tags)
+	return w.Client.WithUploadOptions(opts)
+}
+
+// moveToSent moves an uploaded file into the rule's sent folder, keeping
+// its relative path and never overwriting an earlier file
+func (w *Watcher) moveToSent(rule config.WatchRule, filePath, rel string) error {
+	sent := sentFolder(rule)
+	if sent == "" {
+		return nil
+	}
+
+	dest := filepath.Join(sent, rel)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+
+	ext := filepath.Ext(dest)
+	base := strings.TrimSuffix(dest, ext)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(dest); os.IsNotExist(err) {
+			break
+		}
+		dest = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	if err := os.Rename(filePath, dest); err != nil {
+		return fmt.Errorf("uploaded but failed to move to %s: %w", sent, err)
+	}
+	return nil
+}
+
+// ruleFor returns the rule whose folder contains filePath
+func (w *Watcher) ruleFor(filePath string) (config.WatchRule, bool) {
+	for _, rule := range w.Rules {
+		rel, err := filepath.Rel(rule.Folder, filePath)
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if w.isSentFolder(rule, filePath) {
+			continue
+		}
+		if !rule.Recursive && strings.ContainsRune(rel, filepath.Separator) {
+			continue
+		}
+		return rule, true
+	}
+	return config.WatchRule{}, false
+}
+
+// isSentFolder reports whether p is inside the rule's sent folder
+func (w *Watcher) isSentFolder(rule config.WatchRule, p string) bool {
+	sent := sentFolder(rule)
+	if sent == "" {
+		return false
+	}
+	rel, err := filepath.Rel(sent, p)
+	return err == nil && !strings.HasPrefix(rel, "..")
+}
+
+// sentFolder resolves the sent folder, relative paths being taken from the watched folder
+func sentFolder(rule config.WatchRule) string {
+	if rule.SentFolder == "" || filepath.IsAbs(rule.SentFolder) {
+		return rule.SentFolder
+	}
+	return filepath.Join(rule.Folder, rule.SentFolder)
+}
+
+// sameModTime compares times allowing for the precision lost in metadata
+func sameModTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d < time.Millisecond && d > -time.Millisecond
+}
+
+func objectKey(prefix, rel string) string {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		return rel
+	}
+	return path.Join(prefix, rel)
+}
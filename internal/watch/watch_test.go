@@ -0,0 +1,98 @@
+package watch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"simple-uploader/internal/config"
+	"simple-uploader/internal/minio"
+)
+
+// newTestWatcher returns a watcher for one folder whose client cannot reach
+// a server and refuses .exe files by policy
+func newTestWatcher(t *testing.T) (*Watcher, config.WatchRule, *[]string) {
+	t.Helper()
+	client, err := minio.NewClient(&config.MinIOConfig{
+		Endpoint:  "127.0.0.1:1",
+		AccessKey: "test",
+		SecretKey: "testsecret",
+		Bucket:    "test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.SetUploadOptions(minio.UploadOptions{
+		Policy: config.PolicyConfig{DeniedExtensions: []string{".exe"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var errs []string
+	rule := config.WatchRule{Folder: t.TempDir()}
+	w := &Watcher{
+		Client:   client,
+		Rules:    []config.WatchRule{rule},
+		OnError:  func(filePath string, err error) { errs = append(errs, filePath) },
+		pending:  make(map[string]*pendingFile),
+		rejected: make(map[string]fileVersion),
+	}
+	return w, rule, &errs
+}
+
+func TestUploadRejectedReportedOnce(t *testing.T) {
+	w, rule, errs := newTestWatcher(t)
+	filePath := filepath.Join(rule.Folder, "setup.exe")
+	if err := os.WriteFile(filePath, []byte("MZ"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w.track(rule, filePath)
+	w.uploadPending(context.Background(), filePath)
+	if len(*errs) != 1 {
+		t.Fatalf("OnError called %d times, want 1", len(*errs))
+	}
+	if _, ok := w.pending[filePath]; ok {
+		t.Error("rejected file is still pending, so it would be retried")
+	}
+
+	// Seen again unchanged, as after another write event: still refused
+	w.track(rule, filePath)
+	if _, ok := w.pending[filePath]; ok {
+		t.Error("unchanged rejected file was tracked again")
+	}
+
+	// A new version gets another chance
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filePath, later, later); err != nil {
+		t.Fatal(err)
+	}
+	w.track(rule, filePath)
+	if _, ok := w.pending[filePath]; !ok {
+		t.Error("changed file was not tracked again")
+	}
+}
+
+func TestUploadOfflineRetried(t *testing.T) {
+	w, rule, errs := newTestWatcher(t)
+	filePath := filepath.Join(rule.Folder, "report.txt")
+	if err := os.WriteFile(filePath, []byte("hello"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w.track(rule, filePath)
+	w.uploadPending(context.Background(), filePath)
+	if len(*errs) != 1 {
+		t.Fatalf("OnError called %d times, want 1", len(*errs))
+	}
+	p, ok := w.pending[filePath]
+	if !ok {
+		t.Fatal("file dropped after a network error")
+	}
+	if !p.notBefore.After(time.Now()) {
+		t.Error("no retry delay after a network error")
+	}
+}
@@ -35,6 +35,10 @@ try {
     Write-Host "Building installer.exe..." -ForegroundColor Yellow
     go build -ldflags="-s -w" -o "$OutputDir\installer.exe" .\cmd\installer
 
+    # Build command line tool (console mode)
+    Write-Host "Building minioctl.exe..." -ForegroundColor Yellow
+    go build -ldflags="-s -w" -o "$OutputDir\minioctl.exe" .\cmd\minioctl
+
     # Copy config template
     Write-Host "`nCopying config template..." -ForegroundColor Yellow
     Copy-Item "config.json" "$OutputDir\config.json"
@@ -81,6 +85,7 @@ FILES
 - uploader.exe  : Handles file uploads (called from context menu)
 - mounter.exe   : System tray app for drive mounting
 - installer.exe : Install/uninstall context menu and startup
+- minioctl.exe  : Command line tool (usage reports, ...)
 - rclone.exe    : Required for drive mounting (download separately)
 - config.json   : Your MinIO configuration
 "@